@@ -0,0 +1,451 @@
+package database
+
+import (
+	"database/sql"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// slowQueryThreshold is how long a statement may run before it gets logged.
+// Override with SLOW_QUERY_THRESHOLD_MS (milliseconds); 0 disables the
+// logging entirely.
+var slowQueryThreshold = slowQueryThresholdFromEnv()
+
+func slowQueryThresholdFromEnv() time.Duration {
+	if v, err := strconv.Atoi(os.Getenv("SLOW_QUERY_THRESHOLD_MS")); err == nil && v >= 0 {
+		return time.Duration(v) * time.Millisecond
+	}
+	return 200 * time.Millisecond
+}
+
+// logIfSlow logs a statement that ran longer than the threshold. Only the
+// SQL text with its placeholders is printed — bound parameters are never
+// logged, so emails, addresses and other user data stay out of the logs.
+// Use as `defer logIfSlow(query, time.Now())`.
+func logIfSlow(query string, start time.Time) {
+	if slowQueryThreshold <= 0 {
+		return
+	}
+	if d := time.Since(start); d >= slowQueryThreshold {
+		log.Printf("slow query (%s): %s", d, strings.Join(strings.Fields(query), " "))
+	}
+}
+
+// Row defers the slow-query timing of a single-row query until Scan, because
+// database/sql does not actually execute the statement before then.
+type Row struct {
+	row   *sql.Row
+	query string
+	start time.Time
+}
+
+// Scan reads the row's columns into dest.
+func (r *Row) Scan(dest ...interface{}) error {
+	defer logIfSlow(r.query, r.start)
+	return r.row.Scan(dest...)
+}
+
+// Rows times a multi-row query through to Close, since the driver does most
+// of its work lazily during iteration rather than inside Query itself.
+type Rows struct {
+	*sql.Rows
+	query string
+	start time.Time
+	timed bool
+}
+
+// Close releases the rows and records the query's total duration. It is safe
+// to call more than once; only the first call is timed.
+func (r *Rows) Close() error {
+	if !r.timed {
+		r.timed = true
+		logIfSlow(r.query, r.start)
+	}
+	return r.Rows.Close()
+}
+
+// DB is the shared database handle used by all handlers.
+var DB *Conn
+
+// Conn wraps sql.DB so the handlers can keep writing `?` placeholders no
+// matter which driver is active: for Postgres every statement is rebound to
+// $1..$N on the way through. The driver is selected with DB_DRIVER (sqlite3,
+// the default, or postgres); Postgres reads its connection string from
+// DB_DSN.
+type Conn struct {
+	db     *sql.DB
+	driver string
+}
+
+// Exec runs a statement with driver-appropriate placeholders.
+func (c *Conn) Exec(query string, args ...interface{}) (sql.Result, error) {
+	defer logIfSlow(query, time.Now())
+	return c.db.Exec(rebind(c.driver, query), args...)
+}
+
+// Query runs a query with driver-appropriate placeholders.
+func (c *Conn) Query(query string, args ...interface{}) (*Rows, error) {
+	start := time.Now()
+	rows, err := c.db.Query(rebind(c.driver, query), args...)
+	if err != nil {
+		return nil, err
+	}
+	return &Rows{Rows: rows, query: query, start: start}, nil
+}
+
+// QueryRow runs a single-row query with driver-appropriate placeholders.
+func (c *Conn) QueryRow(query string, args ...interface{}) *Row {
+	return &Row{row: c.db.QueryRow(rebind(c.driver, query), args...), query: query, start: time.Now()}
+}
+
+// Insert runs an INSERT and returns the generated id. lib/pq does not
+// implement LastInsertId, so on Postgres the statement is executed with
+// RETURNING id and the value read back instead.
+func (c *Conn) Insert(query string, args ...interface{}) (int64, error) {
+	return insertRow(c.driver, c.Exec, c.QueryRow, query, args)
+}
+
+// Begin starts a transaction sharing the same placeholder handling.
+func (c *Conn) Begin() (*Tx, error) {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{tx: tx, driver: c.driver}, nil
+}
+
+// Close closes the underlying database handle.
+func (c *Conn) Close() error {
+	return c.db.Close()
+}
+
+// Tx is a transaction with the same placeholder handling as Conn.
+type Tx struct {
+	tx     *sql.Tx
+	driver string
+}
+
+// Exec runs a statement in the transaction.
+func (t *Tx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	defer logIfSlow(query, time.Now())
+	return t.tx.Exec(rebind(t.driver, query), args...)
+}
+
+// Query runs a query in the transaction.
+func (t *Tx) Query(query string, args ...interface{}) (*Rows, error) {
+	start := time.Now()
+	rows, err := t.tx.Query(rebind(t.driver, query), args...)
+	if err != nil {
+		return nil, err
+	}
+	return &Rows{Rows: rows, query: query, start: start}, nil
+}
+
+// QueryRow runs a single-row query in the transaction.
+func (t *Tx) QueryRow(query string, args ...interface{}) *Row {
+	return &Row{row: t.tx.QueryRow(rebind(t.driver, query), args...), query: query, start: time.Now()}
+}
+
+// Insert runs an INSERT in the transaction and returns the generated id.
+func (t *Tx) Insert(query string, args ...interface{}) (int64, error) {
+	return insertRow(t.driver, t.Exec, t.QueryRow, query, args)
+}
+
+// Commit commits the transaction.
+func (t *Tx) Commit() error { return t.tx.Commit() }
+
+// Rollback aborts the transaction.
+func (t *Tx) Rollback() error { return t.tx.Rollback() }
+
+func insertRow(
+	driver string,
+	exec func(string, ...interface{}) (sql.Result, error),
+	queryRow func(string, ...interface{}) *Row,
+	query string,
+	args []interface{},
+) (int64, error) {
+	if driver == "postgres" {
+		var id int64
+		err := queryRow(query+" RETURNING id", args...).Scan(&id)
+		return id, err
+	}
+	res, err := exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// rebind rewrites `?` placeholders to the $1..$N form Postgres expects,
+// leaving question marks inside string literals alone. SQLite statements
+// pass through untouched.
+func rebind(driver, query string) string {
+	if driver != "postgres" {
+		return query
+	}
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+	n := 0
+	inString := false
+	for i := 0; i < len(query); i++ {
+		ch := query[i]
+		switch {
+		case ch == '\'':
+			inString = !inString
+			b.WriteByte(ch)
+		case ch == '?' && !inString:
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+		default:
+			b.WriteByte(ch)
+		}
+	}
+	return b.String()
+}
+
+// InitDB opens the database — SQLite at path by default, or Postgres when
+// DB_DRIVER=postgres with DB_DSN as the connection string — and creates the
+// schema if needed.
+func InitDB(path string) error {
+	driver := os.Getenv("DB_DRIVER")
+	if driver == "" {
+		driver = "sqlite3"
+	}
+	dsn := path
+	if driver == "sqlite3" {
+		// SQLite leaves foreign keys off per connection unless asked, which
+		// would silently disable every ON DELETE clause in the schema. The
+		// DSN parameter applies the pragma to each pooled connection, not
+		// just the one that happens to run it first.
+		dsn = path + "?_foreign_keys=on"
+	}
+	if driver == "postgres" {
+		dsn = os.Getenv("DB_DSN")
+	}
+
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return err
+	}
+	configurePool(db, driver)
+	if err = db.Ping(); err != nil {
+		return err
+	}
+	DB = &Conn{db: db, driver: driver}
+	return createTables(driver)
+}
+
+// configurePool bounds the connection pool instead of leaving database/sql's
+// unlimited defaults in place. The limits come from DB_MAX_OPEN_CONNS,
+// DB_MAX_IDLE_CONNS and DB_CONN_MAX_LIFETIME_MINUTES when set. For SQLite the
+// open limit defaults to 1: the file supports only one writer at a time, and
+// a second pooled connection contending for it is what produces "database is
+// locked" errors under load.
+func configurePool(db *sql.DB, driver string) {
+	maxOpen, maxIdle := 25, 5
+	if driver == "sqlite3" {
+		maxOpen, maxIdle = 1, 1
+	}
+	db.SetMaxOpenConns(poolSettingFromEnv("DB_MAX_OPEN_CONNS", maxOpen))
+	db.SetMaxIdleConns(poolSettingFromEnv("DB_MAX_IDLE_CONNS", maxIdle))
+	db.SetConnMaxLifetime(time.Duration(poolSettingFromEnv("DB_CONN_MAX_LIFETIME_MINUTES", 30)) * time.Minute)
+}
+
+func poolSettingFromEnv(name string, fallback int) int {
+	if v, err := strconv.Atoi(os.Getenv(name)); err == nil && v > 0 {
+		return v
+	}
+	return fallback
+}
+
+// pgReplacements translates the SQLite dialect of the schema below into
+// Postgres. The schema deliberately sticks to constructs that survive this
+// mechanical mapping, so there is only one copy to maintain.
+var pgReplacements = strings.NewReplacer(
+	"INTEGER PRIMARY KEY AUTOINCREMENT", "SERIAL PRIMARY KEY",
+	"REAL", "DOUBLE PRECISION",
+	"(datetime('now'))", "(to_char(now() AT TIME ZONE 'utc', 'YYYY-MM-DD HH24:MI:SS'))",
+)
+
+func createTables(driver string) error {
+	schema := `
+	CREATE TABLE IF NOT EXISTS users (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		email TEXT NOT NULL UNIQUE,
+		password TEXT NOT NULL,
+		role TEXT NOT NULL DEFAULT 'client',
+		wholesale_address TEXT NOT NULL DEFAULT '',
+		email_verified INTEGER NOT NULL DEFAULT 0
+	);
+
+	CREATE TABLE IF NOT EXISTS musicians (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS ensembles (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		archived INTEGER NOT NULL DEFAULT 0
+	);
+
+	CREATE TABLE IF NOT EXISTS ensemble_musicians (
+		ensemble_id INTEGER NOT NULL REFERENCES ensembles(id) ON DELETE CASCADE,
+		musician_id INTEGER NOT NULL REFERENCES musicians(id) ON DELETE CASCADE,
+		PRIMARY KEY (ensemble_id, musician_id)
+	);
+
+	CREATE TABLE IF NOT EXISTS tracks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		duration INTEGER NOT NULL DEFAULT 0,
+		musician_id INTEGER REFERENCES musicians(id) ON DELETE SET NULL,
+		ensemble_id INTEGER REFERENCES ensembles(id) ON DELETE SET NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS records (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		price REAL NOT NULL,
+		stock INTEGER NOT NULL DEFAULT 0,
+		release_date TEXT NOT NULL DEFAULT '',
+		date_precision TEXT NOT NULL DEFAULT 'day',
+		label TEXT NOT NULL DEFAULT '',
+		genre TEXT NOT NULL DEFAULT '',
+		sold_last_year INTEGER NOT NULL DEFAULT 0,
+		sold_current_year INTEGER NOT NULL DEFAULT 0,
+		weight_grams INTEGER NOT NULL DEFAULT 0,
+		max_per_order INTEGER NOT NULL DEFAULT 0,
+		archived INTEGER NOT NULL DEFAULT 0
+	);
+
+	CREATE TABLE IF NOT EXISTS record_tracks (
+		record_id INTEGER NOT NULL REFERENCES records(id) ON DELETE CASCADE,
+		track_id INTEGER NOT NULL REFERENCES tracks(id) ON DELETE CASCADE,
+		PRIMARY KEY (record_id, track_id)
+	);
+
+	-- Secondary indexes for the hot foreign-key lookups: tracks by owner and
+	-- record_tracks by track. record_tracks by record is already the primary
+	-- key's leftmost column, as is cart_items by user via the unique index
+	-- below, so neither needs an index of its own.
+	CREATE INDEX IF NOT EXISTS idx_tracks_musician ON tracks (musician_id);
+	CREATE INDEX IF NOT EXISTS idx_tracks_ensemble ON tracks (ensemble_id);
+	CREATE INDEX IF NOT EXISTS idx_record_tracks_track ON record_tracks (track_id);
+
+	CREATE TABLE IF NOT EXISTS price_history (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		record_id INTEGER NOT NULL REFERENCES records(id) ON DELETE CASCADE,
+		price REAL NOT NULL,
+		changed_at TEXT NOT NULL DEFAULT (datetime('now'))
+	);
+
+	CREATE TABLE IF NOT EXISTS cart_items (
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		record_id INTEGER NOT NULL REFERENCES records(id) ON DELETE CASCADE,
+		quantity INTEGER NOT NULL DEFAULT 1,
+		PRIMARY KEY (user_id, record_id)
+	);
+
+	-- The cart upsert's ON CONFLICT target depends on (user_id, record_id)
+	-- being unique; keep a dedicated index so that holds even if the primary
+	-- key ever changes shape.
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_cart_items_user_record
+		ON cart_items (user_id, record_id);
+
+	CREATE TABLE IF NOT EXISTS cart_events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		record_id INTEGER NOT NULL,
+		action TEXT NOT NULL,
+		quantity INTEGER NOT NULL DEFAULT 0,
+		created_at TEXT NOT NULL DEFAULT (datetime('now'))
+	);
+
+	CREATE TABLE IF NOT EXISTS audit_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		admin_id INTEGER NOT NULL,
+		action TEXT NOT NULL,
+		target_type TEXT NOT NULL,
+		target_id INTEGER NOT NULL DEFAULT 0,
+		created_at TEXT NOT NULL DEFAULT (datetime('now'))
+	);
+
+	CREATE TABLE IF NOT EXISTS promo_codes (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		code TEXT NOT NULL UNIQUE,
+		percent_off REAL NOT NULL DEFAULT 0,
+		fixed_off REAL NOT NULL DEFAULT 0,
+		valid_from TEXT NOT NULL,
+		valid_to TEXT NOT NULL,
+		usage_limit INTEGER NOT NULL DEFAULT 0,
+		used_count INTEGER NOT NULL DEFAULT 0
+	);
+
+	CREATE TABLE IF NOT EXISTS revoked_tokens (
+		token_hash TEXT PRIMARY KEY,
+		expires_at TEXT NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS refresh_tokens (
+		token_hash TEXT PRIMARY KEY,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		expires_at TEXT NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS email_verification_tokens (
+		token_hash TEXT PRIMARY KEY,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS password_reset_tokens (
+		token_hash TEXT PRIMARY KEY,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		expires_at TEXT NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS login_attempts (
+		user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+		failures INTEGER NOT NULL DEFAULT 0,
+		locked_until TEXT NOT NULL DEFAULT ''
+	);
+
+	CREATE TABLE IF NOT EXISTS job_state (
+		name TEXT PRIMARY KEY,
+		value TEXT NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS inventory_snapshots (
+		snapshot_date TEXT PRIMARY KEY,
+		total_units INTEGER NOT NULL DEFAULT 0,
+		total_value REAL NOT NULL DEFAULT 0
+	);
+
+	CREATE TABLE IF NOT EXISTS orders (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		created_at TEXT NOT NULL DEFAULT (datetime('now')),
+		total REAL NOT NULL DEFAULT 0
+	);
+
+	CREATE TABLE IF NOT EXISTS order_items (
+		order_id INTEGER NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+		record_id INTEGER NOT NULL REFERENCES records(id) ON DELETE CASCADE,
+		quantity INTEGER NOT NULL,
+		price REAL NOT NULL,
+		PRIMARY KEY (order_id, record_id)
+	);
+	`
+	if driver == "postgres" {
+		schema = pgReplacements.Replace(schema)
+	}
+	_, err := DB.Exec(schema)
+	return err
+}
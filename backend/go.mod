@@ -0,0 +1,12 @@
+module backend
+
+go 1.21.6
+
+require (
+	github.com/golang-jwt/jwt/v5 v5.2.1
+	github.com/gorilla/mux v1.8.1
+	github.com/mattn/go-sqlite3 v1.14.22
+	golang.org/x/crypto v0.21.0
+)
+
+require github.com/lib/pq v1.10.9
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"backend/database"
+	"backend/models"
+)
+
+// Known enum values for audit filtering; filters outside these sets get 400.
+var (
+	auditActions = map[string]bool{
+		"create": true, "update": true, "delete": true, "merge": true, "import": true,
+	}
+	auditTargetTypes = map[string]bool{
+		"record": true, "track": true, "ensemble": true, "musician": true, "user": true,
+	}
+)
+
+// recordAudit writes one audit row for an admin mutation. Failures are
+// logged, not surfaced: auditing must never break the operation itself.
+func recordAudit(r *http.Request, action, targetType string, targetID int) {
+	adminID := userIDFromContext(r)
+	if _, err := database.DB.Exec(
+		"INSERT INTO audit_log (admin_id, action, target_type, target_id) VALUES (?, ?, ?, ?)",
+		adminID, action, targetType, targetID,
+	); err != nil {
+		log.Printf("audit write failed: %v", err)
+	}
+}
+
+type auditEntry struct {
+	ID         int    `json:"id"`
+	AdminID    int    `json:"admin_id"`
+	Action     string `json:"action"`
+	TargetType string `json:"target_type"`
+	TargetID   int    `json:"target_id"`
+	CreatedAt  string `json:"created_at"`
+}
+
+// GetAuditLogHandler returns the audit log filtered by action, target type,
+// acting admin and date range, paginated, or as a CSV export of the whole
+// filtered set when format=csv.
+func GetAuditLogHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	where := "WHERE 1=1"
+	args := []interface{}{}
+
+	if action := q.Get("action"); action != "" {
+		if !auditActions[action] {
+			respondWithError(w, http.StatusBadRequest, "unknown action filter")
+			return
+		}
+		where += " AND action = ?"
+		args = append(args, action)
+	}
+	if target := q.Get("target"); target != "" {
+		if !auditTargetTypes[target] {
+			respondWithError(w, http.StatusBadRequest, "unknown target filter")
+			return
+		}
+		where += " AND target_type = ?"
+		args = append(args, target)
+	}
+	if adminID := q.Get("adminId"); adminID != "" {
+		id, err := strconv.Atoi(adminID)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "invalid adminId filter")
+			return
+		}
+		where += " AND admin_id = ?"
+		args = append(args, id)
+	}
+	if from := q.Get("from"); from != "" {
+		if _, err := time.Parse("2006-01-02", from); err != nil {
+			respondWithError(w, http.StatusBadRequest, "from must be in YYYY-MM-DD format")
+			return
+		}
+		where += " AND created_at >= ?"
+		args = append(args, from)
+	}
+	if to := q.Get("to"); to != "" {
+		day, err := time.Parse("2006-01-02", to)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "to must be in YYYY-MM-DD format")
+			return
+		}
+		where += " AND created_at < ?"
+		args = append(args, day.AddDate(0, 0, 1).Format("2006-01-02"))
+	}
+
+	if q.Get("format") == "csv" {
+		rows, err := database.DB.Query(
+			"SELECT id, admin_id, action, target_type, target_id, created_at FROM audit_log "+where+" ORDER BY id", args...,
+		)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		defer rows.Close()
+
+		csvRows := [][]string{}
+		for rows.Next() {
+			var e auditEntry
+			if err := rows.Scan(&e.ID, &e.AdminID, &e.Action, &e.TargetType, &e.TargetID, &e.CreatedAt); err != nil {
+				respondWithError(w, http.StatusInternalServerError, "database error")
+				return
+			}
+			csvRows = append(csvRows, []string{
+				strconv.Itoa(e.ID), strconv.Itoa(e.AdminID), e.Action, e.TargetType,
+				strconv.Itoa(e.TargetID), e.CreatedAt,
+			})
+		}
+		writeCSV(w, "audit-log.csv", []string{"id", "admin_id", "action", "target_type", "target_id", "created_at"}, csvRows)
+		return
+	}
+
+	var total int
+	if err := database.DB.QueryRow("SELECT COUNT(*) FROM audit_log "+where, args...).Scan(&total); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	page, pageSize := parsePagination(r)
+	rows, err := database.DB.Query(
+		"SELECT id, admin_id, action, target_type, target_id, created_at FROM audit_log "+where+
+			" ORDER BY id DESC LIMIT ? OFFSET ?",
+		append(args, pageSize, (page-1)*pageSize)...,
+	)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	defer rows.Close()
+
+	entries := []auditEntry{}
+	for rows.Next() {
+		var e auditEntry
+		if err := rows.Scan(&e.ID, &e.AdminID, &e.Action, &e.TargetType, &e.TargetID, &e.CreatedAt); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		entries = append(entries, e)
+	}
+
+	respondWithJSON(w, http.StatusOK, models.NewPage(entries, total, page, pageSize))
+}
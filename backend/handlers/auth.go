@@ -0,0 +1,735 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"net/mail"
+	"os"
+	"strings"
+	"time"
+
+	"backend/database"
+	"backend/models"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// jwtSecret signs every access token. It comes from JWT_SECRET; a deployment
+// marked APP_ENV=production refuses to start without one, since a well-known
+// key lets anyone mint admin tokens. Development falls back to an insecure
+// constant so the server still runs out of the box.
+var jwtSecret = jwtSecretFromEnv()
+
+func jwtSecretFromEnv() []byte {
+	if v := os.Getenv("JWT_SECRET"); v != "" {
+		return []byte(v)
+	}
+	if os.Getenv("APP_ENV") == "production" {
+		log.Fatalf("JWT_SECRET must be set when APP_ENV=production")
+	}
+	log.Printf("JWT_SECRET not set, signing tokens with the insecure development key")
+	return []byte("supersecretkey")
+}
+
+// Access tokens are short-lived now that the refresh flow can renew them
+// silently; refresh tokens live long enough that an active user never has to
+// re-enter credentials. Override the access lifetime with JWT_EXPIRY (any Go
+// duration, e.g. "5m" or "24h").
+var accessTokenTTL = accessTokenTTLFromEnv()
+
+const refreshTokenTTL = 30 * 24 * time.Hour
+
+// accessTokenTTLFromEnv refuses to start on an unparseable JWT_EXPIRY rather
+// than falling back silently — a deployment that asked for "15" and got the
+// default would hand out tokens with the wrong lifetime without anyone
+// noticing.
+func accessTokenTTLFromEnv() time.Duration {
+	v := os.Getenv("JWT_EXPIRY")
+	if v == "" {
+		return 15 * time.Minute
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		log.Fatalf("invalid JWT_EXPIRY %q: want a positive duration such as 15m or 24h", v)
+	}
+	return d
+}
+
+// Claims is the JWT payload issued on login.
+type Claims struct {
+	UserID int    `json:"user_id"`
+	Role   string `json:"role"`
+	jwt.RegisteredClaims
+}
+
+type credentials struct {
+	Email            string `json:"email"`
+	Password         string `json:"password"`
+	WholesaleAddress string `json:"wholesale_address"`
+}
+
+// normalizeEmail trims, lowercases and validates an address, so Foo@x.com
+// and foo@x.com are the same account and garbage never reaches the UNIQUE
+// constraint. Comparing the parsed address back against the input rejects
+// display-name forms like "Foo <foo@x.com>".
+func normalizeEmail(email string) (string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return "", errors.New("invalid email address")
+	}
+	return email, nil
+}
+
+// RegisterHandler creates a new client account, unless the deployment has
+// closed public signups.
+func RegisterHandler(w http.ResponseWriter, r *http.Request) {
+	if !registrationOpen {
+		respondWithError(w, http.StatusForbidden, "registration is closed")
+		return
+	}
+
+	var creds credentials
+	if err := decodeJSONBody(r, &creds); err != nil {
+		if err == errEmptyBody {
+			respondWithError(w, http.StatusBadRequest, "request body is required")
+			return
+		}
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if creds.Email == "" || creds.Password == "" {
+		respondWithError(w, http.StatusBadRequest, "email and password are required")
+		return
+	}
+	email, err := normalizeEmail(creds.Email)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := validatePassword(creds.Password); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := validateWholesaleAddress(creds.WholesaleAddress); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(creds.Password), bcrypt.DefaultCost)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not hash password")
+		return
+	}
+
+	address := sanitizeText(creds.WholesaleAddress)
+	id, err := database.DB.Insert(
+		"INSERT INTO users (email, password, role, wholesale_address) VALUES (?, ?, 'client', ?)",
+		email, string(hash), address,
+	)
+	if err != nil {
+		respondWithError(w, http.StatusConflict, "user with this email already exists")
+		return
+	}
+
+	verification, err := issueEmailVerificationToken(int(id))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not generate token")
+		return
+	}
+
+	// The token travels only through the mailer: handing it back to the
+	// caller would let any client verify itself immediately, making the
+	// verified-email checkout gate a no-op.
+	sendMailAsync(email, "Welcome to the record store", "Your account has been created.")
+	sendMailAsync(email, "Verify your email", "Your verification token: "+verification)
+
+	respondWithJSON(w, http.StatusCreated, models.User{
+		ID:               int(id),
+		Email:            email,
+		Role:             "client",
+		WholesaleAddress: address,
+	})
+}
+
+// issueEmailVerificationToken mints the single-use token that proves control
+// of an account's email address. Only the hash is stored; the row has no
+// expiry because the account simply stays unverified until the token is used,
+// and it dies with the user via the cascade.
+func issueEmailVerificationToken(userID int) (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(raw)
+	if _, err := database.DB.Exec(
+		"INSERT INTO email_verification_tokens (token_hash, user_id) VALUES (?, ?)",
+		tokenHash(token), userID,
+	); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+type verifyEmailPayload struct {
+	Token string `json:"token"`
+}
+
+// VerifyEmailHandler flips the account's email_verified flag when presented
+// with a valid verification token, consuming the token in the process.
+func VerifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	var payload verifyEmailPayload
+	if err := decodeJSONBody(r, &payload); err != nil || payload.Token == "" {
+		respondWithError(w, http.StatusBadRequest, "token is required")
+		return
+	}
+	hash := tokenHash(payload.Token)
+
+	var userID int
+	err := database.DB.QueryRow(
+		"SELECT user_id FROM email_verification_tokens WHERE token_hash = ?", hash,
+	).Scan(&userID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusBadRequest, "invalid verification token")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	if _, err := database.DB.Exec("UPDATE users SET email_verified = 1 WHERE id = ?", userID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	if _, err := database.DB.Exec("DELETE FROM email_verification_tokens WHERE user_id = ?", userID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "email verified"})
+}
+
+// requireVerifiedEmail gates checkout behind a verified address when
+// REQUIRE_VERIFIED_EMAIL=1 is set; browsing and the cart stay open either
+// way, matching how REGISTRATION_CLOSED only shuts the one door it names.
+var requireVerifiedEmail = os.Getenv("REQUIRE_VERIFIED_EMAIL") == "1"
+
+// emailVerified reports whether the user's address has been verified.
+func emailVerified(userID int) (bool, error) {
+	var verified bool
+	err := database.DB.QueryRow("SELECT email_verified FROM users WHERE id = ?", userID).Scan(&verified)
+	return verified, err
+}
+
+// After maxLoginFailures consecutive wrong passwords the account is locked
+// for loginLockout; the counter lives per account in login_attempts, so the
+// lock follows the account rather than the client's IP the way the rate
+// limiter does.
+const (
+	maxLoginFailures = 5
+	loginLockout     = 15 * time.Minute
+)
+
+// loginLocked reports whether the account is still inside a lockout window.
+func loginLocked(userID int) (bool, error) {
+	var lockedUntil string
+	err := database.DB.QueryRow(
+		"SELECT locked_until FROM login_attempts WHERE user_id = ?", userID,
+	).Scan(&lockedUntil)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if lockedUntil == "" {
+		return false, nil
+	}
+	until, err := time.Parse("2006-01-02 15:04:05", lockedUntil)
+	if err != nil {
+		return false, nil
+	}
+	return time.Now().UTC().Before(until), nil
+}
+
+// registerLoginFailure bumps the account's consecutive-failure counter and
+// starts the lockout window once it reaches the threshold. The counter resets
+// when the lock is set, so the next window only opens after another full run
+// of failures.
+func registerLoginFailure(userID int) {
+	if _, err := database.DB.Exec(`
+		INSERT INTO login_attempts (user_id, failures) VALUES (?, 1)
+		ON CONFLICT(user_id) DO UPDATE SET failures = failures + 1`, userID); err != nil {
+		log.Printf("could not record login failure: %v", err)
+		return
+	}
+	var failures int
+	if err := database.DB.QueryRow(
+		"SELECT failures FROM login_attempts WHERE user_id = ?", userID,
+	).Scan(&failures); err != nil {
+		log.Printf("could not record login failure: %v", err)
+		return
+	}
+	if failures < maxLoginFailures {
+		return
+	}
+	lockedUntil := time.Now().UTC().Add(loginLockout).Format("2006-01-02 15:04:05")
+	if _, err := database.DB.Exec(
+		"UPDATE login_attempts SET failures = 0, locked_until = ? WHERE user_id = ?",
+		lockedUntil, userID,
+	); err != nil {
+		log.Printf("could not record login failure: %v", err)
+	}
+}
+
+// clearLoginFailures wipes the account's failure state after a successful
+// login, so the threshold only ever counts consecutive failures.
+func clearLoginFailures(userID int) {
+	if _, err := database.DB.Exec("DELETE FROM login_attempts WHERE user_id = ?", userID); err != nil {
+		log.Printf("could not clear login failures: %v", err)
+	}
+}
+
+// EnsureAdminAccount seeds or repairs the administrator account named by
+// ADMIN_EMAIL/ADMIN_PASSWORD at startup. Registration only ever mints
+// clients and the whole admin surface — including role management — sits
+// behind AdminMiddleware, so on a fresh database there would otherwise be no
+// way to reach any of it. An existing account with that email is promoted,
+// its password left alone; a missing one is created pre-verified. Leaving
+// either variable unset skips the bootstrap.
+func EnsureAdminAccount() error {
+	adminEmail := os.Getenv("ADMIN_EMAIL")
+	adminPassword := os.Getenv("ADMIN_PASSWORD")
+	if adminEmail == "" || adminPassword == "" {
+		return nil
+	}
+	email, err := normalizeEmail(adminEmail)
+	if err != nil {
+		return errors.New("invalid ADMIN_EMAIL: " + err.Error())
+	}
+
+	var id int
+	var role string
+	err = database.DB.QueryRow("SELECT id, role FROM users WHERE email = ?", email).Scan(&id, &role)
+	switch {
+	case err == sql.ErrNoRows:
+		if err := validatePassword(adminPassword); err != nil {
+			return errors.New("invalid ADMIN_PASSWORD: " + err.Error())
+		}
+		hash, err := bcrypt.GenerateFromPassword([]byte(adminPassword), bcrypt.DefaultCost)
+		if err != nil {
+			return err
+		}
+		if _, err := database.DB.Exec(
+			"INSERT INTO users (email, password, role, email_verified) VALUES (?, ?, 'admin', 1)",
+			email, string(hash),
+		); err != nil {
+			return err
+		}
+		log.Printf("created bootstrap admin account %s", email)
+	case err != nil:
+		return err
+	case role != "admin":
+		if _, err := database.DB.Exec("UPDATE users SET role = 'admin' WHERE id = ?", id); err != nil {
+			return err
+		}
+		log.Printf("promoted %s to admin", email)
+	}
+	return nil
+}
+
+// LoginHandler checks credentials and returns a signed JWT.
+func LoginHandler(w http.ResponseWriter, r *http.Request) {
+	var creds credentials
+	if err := decodeJSONBody(r, &creds); err != nil {
+		if err == errEmptyBody {
+			respondWithError(w, http.StatusBadRequest, "request body is required")
+			return
+		}
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	// Lookups use the same trimmed, lowercased form the account was stored
+	// under, so Foo@x.com logs into the foo@x.com account.
+	var user models.User
+	err := database.DB.QueryRow(
+		"SELECT id, email, password, role FROM users WHERE email = ?",
+		strings.ToLower(strings.TrimSpace(creds.Email)),
+	).Scan(&user.ID, &user.Email, &user.Password, &user.Role)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusUnauthorized, "invalid email or password")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	// The lock is checked before the password, so a locked account answers
+	// 423 even to the correct credentials until the window passes.
+	locked, err := loginLocked(user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	if locked {
+		respondWithError(w, http.StatusLocked, "account temporarily locked, try again later")
+		return
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(creds.Password)) != nil {
+		registerLoginFailure(user.ID)
+		respondWithError(w, http.StatusUnauthorized, "invalid email or password")
+		return
+	}
+	clearLoginFailures(user.ID)
+
+	token, err := generateToken(user.ID, user.Role)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not generate token")
+		return
+	}
+	refresh, err := issueRefreshToken(user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not generate token")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"token": token, "refreshToken": refresh})
+}
+
+// issueRefreshToken mints an opaque refresh token for a user and stores its
+// hash server-side; like the revocation denylist, the database never holds a
+// usable credential.
+func issueRefreshToken(userID int) (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(raw)
+
+	expiresAt := time.Now().UTC().Add(refreshTokenTTL)
+	if _, err := database.DB.Exec(
+		"INSERT INTO refresh_tokens (token_hash, user_id, expires_at) VALUES (?, ?, ?)",
+		tokenHash(token), userID, expiresAt.Format("2006-01-02 15:04:05"),
+	); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+type refreshPayload struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+// RefreshTokenHandler exchanges a valid refresh token for a fresh access
+// token. The refresh token is rotated on every use — the presented one is
+// deleted and a new one returned — so a leaked token stops working the
+// moment its rightful owner refreshes.
+func RefreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var payload refreshPayload
+	if err := decodeJSONBody(r, &payload); err != nil || payload.RefreshToken == "" {
+		respondWithError(w, http.StatusBadRequest, "refreshToken is required")
+		return
+	}
+	hash := tokenHash(payload.RefreshToken)
+
+	var userID int
+	var expiresAt string
+	err := database.DB.QueryRow(
+		"SELECT user_id, expires_at FROM refresh_tokens WHERE token_hash = ?", hash,
+	).Scan(&userID, &expiresAt)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusUnauthorized, "invalid refresh token")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	expiry, err := time.Parse("2006-01-02 15:04:05", expiresAt)
+	if err != nil || time.Now().UTC().After(expiry) {
+		database.DB.Exec("DELETE FROM refresh_tokens WHERE token_hash = ?", hash)
+		respondWithError(w, http.StatusUnauthorized, "refresh token has expired")
+		return
+	}
+
+	// The role is read from the database, not baked into the refresh token,
+	// so a promotion or demotion takes effect on the next refresh.
+	var role string
+	if err := database.DB.QueryRow("SELECT role FROM users WHERE id = ?", userID).Scan(&role); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	if _, err := database.DB.Exec("DELETE FROM refresh_tokens WHERE token_hash = ?", hash); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	token, err := generateToken(userID, role)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not generate token")
+		return
+	}
+	refresh, err := issueRefreshToken(userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not generate token")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"token": token, "refreshToken": refresh})
+}
+
+// passwordResetTTL is how long a reset token stays usable; an hour covers a
+// slow inbox without leaving a live credential around for days.
+const passwordResetTTL = time.Hour
+
+type resetRequestPayload struct {
+	Email string `json:"email"`
+}
+
+// RequestPasswordResetHandler mints a single-use reset token for the account
+// behind an email address. Like refresh tokens, only the hash is stored;
+// issuing a new token replaces any outstanding one for the user. The token
+// travels only through the mailer — returning it to the caller would let
+// anyone who knows an email set that account's password — and the response
+// is identical whether or not the account exists.
+func RequestPasswordResetHandler(w http.ResponseWriter, r *http.Request) {
+	var payload resetRequestPayload
+	if err := decodeJSONBody(r, &payload); err != nil || payload.Email == "" {
+		respondWithError(w, http.StatusBadRequest, "email is required")
+		return
+	}
+
+	var userID int
+	err := database.DB.QueryRow(
+		"SELECT id FROM users WHERE email = ?",
+		strings.ToLower(strings.TrimSpace(payload.Email)),
+	).Scan(&userID)
+	if err == sql.ErrNoRows {
+		respondWithJSON(w, http.StatusOK, map[string]string{"status": "reset token issued if the account exists"})
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not generate token")
+		return
+	}
+	token := hex.EncodeToString(raw)
+
+	if _, err := database.DB.Exec("DELETE FROM password_reset_tokens WHERE user_id = ?", userID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	expiresAt := time.Now().UTC().Add(passwordResetTTL).Format("2006-01-02 15:04:05")
+	if _, err := database.DB.Exec(
+		"INSERT INTO password_reset_tokens (token_hash, user_id, expires_at) VALUES (?, ?, ?)",
+		tokenHash(token), userID, expiresAt,
+	); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	sendMailAsync(payload.Email, "Password reset", "Your password reset token: "+token)
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "reset token issued if the account exists"})
+}
+
+type resetConfirmPayload struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"newPassword"`
+}
+
+// ConfirmPasswordResetHandler exchanges a valid reset token for a new
+// password. The token row is deleted on use and on expiry, so a token works
+// exactly once; a successful reset also drops the user's refresh tokens and
+// lockout state, since whoever held the old password may hold those sessions.
+func ConfirmPasswordResetHandler(w http.ResponseWriter, r *http.Request) {
+	var payload resetConfirmPayload
+	if err := decodeJSONBody(r, &payload); err != nil || payload.Token == "" {
+		respondWithError(w, http.StatusBadRequest, "token and newPassword are required")
+		return
+	}
+	if err := validatePassword(payload.NewPassword); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	hash := tokenHash(payload.Token)
+
+	var userID int
+	var expiresAt string
+	err := database.DB.QueryRow(
+		"SELECT user_id, expires_at FROM password_reset_tokens WHERE token_hash = ?", hash,
+	).Scan(&userID, &expiresAt)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusUnauthorized, "invalid reset token")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	expiry, err := time.Parse("2006-01-02 15:04:05", expiresAt)
+	if err != nil || time.Now().UTC().After(expiry) {
+		database.DB.Exec("DELETE FROM password_reset_tokens WHERE token_hash = ?", hash)
+		respondWithError(w, http.StatusUnauthorized, "reset token has expired")
+		return
+	}
+
+	newHash, err := bcrypt.GenerateFromPassword([]byte(payload.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not hash password")
+		return
+	}
+	if _, err := database.DB.Exec("UPDATE users SET password = ? WHERE id = ?", string(newHash), userID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	if _, err := database.DB.Exec("DELETE FROM password_reset_tokens WHERE token_hash = ?", hash); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	database.DB.Exec("DELETE FROM refresh_tokens WHERE user_id = ?", userID)
+	clearLoginFailures(userID)
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "password reset"})
+}
+
+// ReissueTokenHandler issues a fresh JWT carrying the user's current role as
+// stored in the database, so a promoted or demoted user can pick up the
+// change without logging in again.
+func ReissueTokenHandler(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r)
+
+	var role string
+	err := database.DB.QueryRow("SELECT role FROM users WHERE id = ?", userID).Scan(&role)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "user not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	token, err := generateToken(userID, role)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not generate token")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"token": token})
+}
+
+// tokenHash fingerprints a raw JWT for the revocation denylist; storing the
+// hash rather than the token keeps usable credentials out of the database.
+func tokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// isTokenRevoked reports whether a token has been logged out.
+func isTokenRevoked(token string) bool {
+	var count int
+	if err := database.DB.QueryRow(
+		"SELECT COUNT(*) FROM revoked_tokens WHERE token_hash = ?", tokenHash(token),
+	).Scan(&count); err != nil {
+		// If the denylist cannot be read, failing open would quietly undo
+		// every logout; treat the token as revoked instead.
+		return true
+	}
+	return count > 0
+}
+
+// LogoutHandler revokes the presented token for the rest of its lifetime by
+// recording its hash alongside its expiry, after which the purge job can
+// drop the row.
+func LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	raw := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+	claims := &Claims{}
+	if _, err := jwt.ParseWithClaims(raw, claims, func(t *jwt.Token) (interface{}, error) {
+		return jwtSecret, nil
+	}); err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid or expired token")
+		return
+	}
+
+	expiresAt := time.Now().UTC().Add(accessTokenTTL)
+	if claims.ExpiresAt != nil {
+		expiresAt = claims.ExpiresAt.Time.UTC()
+	}
+	if _, err := database.DB.Exec(
+		"INSERT INTO revoked_tokens (token_hash, expires_at) VALUES (?, ?) ON CONFLICT DO NOTHING",
+		tokenHash(raw), expiresAt.Format("2006-01-02 15:04:05"),
+	); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	// Logging out also drops the user's refresh tokens; otherwise the access
+	// token would die while a stored refresh token silently resurrects the
+	// session.
+	if _, err := database.DB.Exec("DELETE FROM refresh_tokens WHERE user_id = ?", claims.UserID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "logged out"})
+}
+
+// StartRevokedTokenPurgeJob periodically drops denylist and refresh-token
+// rows whose tokens have expired anyway, so both tables stay bounded by
+// their token lifetimes.
+func StartRevokedTokenPurgeJob() {
+	go func() {
+		for range time.Tick(time.Hour) {
+			now := time.Now().UTC().Format("2006-01-02 15:04:05")
+			if _, err := database.DB.Exec("DELETE FROM revoked_tokens WHERE expires_at < ?", now); err != nil {
+				log.Printf("revoked token purge failed: %v", err)
+			}
+			if _, err := database.DB.Exec("DELETE FROM refresh_tokens WHERE expires_at < ?", now); err != nil {
+				log.Printf("refresh token purge failed: %v", err)
+			}
+			if _, err := database.DB.Exec("DELETE FROM password_reset_tokens WHERE expires_at < ?", now); err != nil {
+				log.Printf("password reset token purge failed: %v", err)
+			}
+		}
+	}()
+}
+
+func generateToken(userID int, role string) (string, error) {
+	// The random jti makes every token unique even when two logins land in
+	// the same second; without it, revoking one copy on logout would also
+	// revoke the other's byte-identical token.
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", err
+	}
+	claims := Claims{
+		UserID: userID,
+		Role:   role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        hex.EncodeToString(jti),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(accessTokenTTL)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(jwtSecret)
+}
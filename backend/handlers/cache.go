@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// recordsCacheTTL controls how long a cached records listing stays fresh.
+// Override with RECORDS_CACHE_TTL (seconds); 0 disables the cache.
+var recordsCacheTTL = recordsCacheTTLFromEnv()
+
+func recordsCacheTTLFromEnv() time.Duration {
+	if v, err := strconv.Atoi(os.Getenv("RECORDS_CACHE_TTL")); err == nil && v >= 0 {
+		return time.Duration(v) * time.Second
+	}
+	return 30 * time.Second
+}
+
+// catalogVersion is bumped by every record/track mutation so cached listings
+// from before the change are never served.
+var catalogVersion int64
+
+func bumpCatalogVersion() {
+	atomic.AddInt64(&catalogVersion, 1)
+}
+
+type recordsCacheEntry struct {
+	version int64
+	expires time.Time
+	body    []byte
+}
+
+var (
+	recordsCacheMu sync.Mutex
+	recordsCache   = map[string]recordsCacheEntry{}
+)
+
+// cachedRecordsResponse returns the cached response body for the given query
+// key if it is still within TTL and no catalog mutation happened since.
+func cachedRecordsResponse(key string) ([]byte, bool) {
+	if recordsCacheTTL == 0 {
+		return nil, false
+	}
+	recordsCacheMu.Lock()
+	defer recordsCacheMu.Unlock()
+	entry, ok := recordsCache[key]
+	if !ok || entry.version != atomic.LoadInt64(&catalogVersion) || time.Now().After(entry.expires) {
+		return nil, false
+	}
+	return entry.body, true
+}
+
+// storeRecordsResponse caches a rendered records listing under the query key.
+func storeRecordsResponse(key string, body []byte) {
+	if recordsCacheTTL == 0 {
+		return
+	}
+	recordsCacheMu.Lock()
+	defer recordsCacheMu.Unlock()
+	recordsCache[key] = recordsCacheEntry{
+		version: atomic.LoadInt64(&catalogVersion),
+		expires: time.Now().Add(recordsCacheTTL),
+		body:    body,
+	}
+}
@@ -0,0 +1,281 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"os"
+	"strconv"
+
+	"backend/database"
+	"backend/models"
+
+	"github.com/gorilla/mux"
+)
+
+type cartItemPayload struct {
+	RecordID int `json:"record_id"`
+	Quantity int `json:"quantity"`
+}
+
+// defaultMaxPerOrder is the global per-order quantity cap used when a record
+// has no max_per_order of its own. 0 means unlimited. Override with
+// MAX_PER_ORDER.
+var defaultMaxPerOrder = maxPerOrderFromEnv()
+
+func maxPerOrderFromEnv() int {
+	if v, err := strconv.Atoi(os.Getenv("MAX_PER_ORDER")); err == nil && v > 0 {
+		return v
+	}
+	return 0
+}
+
+// exceedsMaxPerOrder reports whether quantity is over the record's per-order
+// cap, falling back to the global default; a zero cap means unlimited.
+func exceedsMaxPerOrder(recordCap, quantity int) bool {
+	limit := recordCap
+	if limit == 0 {
+		limit = defaultMaxPerOrder
+	}
+	return limit > 0 && quantity > limit
+}
+
+// upsertCartItem adds quantity to the user's cart line for the record,
+// creating the line if it does not exist. The ON CONFLICT target names the
+// (user_id, record_id) columns explicitly, backed by a dedicated unique
+// index, so the accumulate-on-conflict behavior survives schema changes to
+// the primary key.
+func upsertCartItem(db execer, userID, recordID, quantity int) error {
+	_, err := db.Exec(`
+		INSERT INTO cart_items (user_id, record_id, quantity) VALUES (?, ?, ?)
+		ON CONFLICT(user_id, record_id) DO UPDATE SET quantity = quantity + excluded.quantity`,
+		userID, recordID, quantity)
+	return err
+}
+
+// cartResponse wraps the cart lines with the totals the frontend would
+// otherwise have to sum itself.
+type cartResponse struct {
+	Items     []models.CartItem `json:"items"`
+	CartTotal float64           `json:"cartTotal"`
+	ItemCount int               `json:"itemCount"`
+}
+
+// GetCartHandler returns the authenticated user's cart contents with
+// per-line and overall totals computed server-side.
+func GetCartHandler(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r)
+
+	rows, err := database.DB.Query(`
+		SELECT ci.record_id, rec.name, rec.price, ci.quantity
+		FROM cart_items ci
+		JOIN records rec ON rec.id = ci.record_id
+		WHERE ci.user_id = ?
+		ORDER BY ci.record_id`, userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	defer rows.Close()
+
+	resp := cartResponse{Items: []models.CartItem{}}
+	for rows.Next() {
+		var item models.CartItem
+		if err := rows.Scan(&item.RecordID, &item.Name, &item.Price, &item.Quantity); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		item.LineTotal = item.Price * float64(item.Quantity)
+		resp.CartTotal += item.LineTotal
+		resp.ItemCount += item.Quantity
+		resp.Items = append(resp.Items, item)
+	}
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// AddToCartHandler adds a record to the cart, incrementing the quantity if it
+// is already there.
+func AddToCartHandler(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r)
+
+	var payload cartItemPayload
+	if err := decodeJSONBody(r, &payload); err != nil {
+		if err == errEmptyBody {
+			respondWithError(w, http.StatusBadRequest, "request body is required")
+			return
+		}
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if payload.Quantity <= 0 {
+		payload.Quantity = 1
+	}
+
+	// The read-check-upsert sequence runs in one transaction so two
+	// concurrent adds cannot both pass the stock check and oversubscribe
+	// the record between them.
+	tx, err := database.DB.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	defer tx.Rollback()
+
+	var name string
+	var maxPerOrder, stock int
+	err = tx.QueryRow(
+		"SELECT name, max_per_order, stock FROM records WHERE id = ?", payload.RecordID,
+	).Scan(&name, &maxPerOrder, &stock)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "record not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	var current int
+	if err := tx.QueryRow(
+		"SELECT COALESCE((SELECT quantity FROM cart_items WHERE user_id = ? AND record_id = ?), 0)",
+		userID, payload.RecordID,
+	).Scan(&current); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	if exceedsMaxPerOrder(maxPerOrder, current+payload.Quantity) {
+		respondWithError(w, http.StatusConflict, "quantity exceeds the per-order limit for "+name)
+		return
+	}
+	if current+payload.Quantity > stock {
+		respondWithJSON(w, http.StatusConflict, map[string]interface{}{
+			"error":     "insufficient stock",
+			"available": stock,
+		})
+		return
+	}
+
+	if err := upsertCartItem(tx, userID, payload.RecordID, payload.Quantity); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	recordCartEvent(userID, payload.RecordID, "add", payload.Quantity)
+	respondWithJSON(w, http.StatusCreated, map[string]string{"status": "added"})
+}
+
+// UpdateCartHandler sets the quantity of a cart line.
+func UpdateCartHandler(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r)
+
+	recordID, err := strconv.Atoi(mux.Vars(r)["recordId"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid record id")
+		return
+	}
+
+	var payload cartItemPayload
+	if err := decodeJSONBody(r, &payload); err != nil {
+		if err == errEmptyBody {
+			respondWithError(w, http.StatusBadRequest, "request body is required")
+			return
+		}
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if payload.Quantity <= 0 {
+		respondWithError(w, http.StatusBadRequest, "quantity must be positive")
+		return
+	}
+
+	var name string
+	var maxPerOrder, stock int
+	err = database.DB.QueryRow(
+		"SELECT name, max_per_order, stock FROM records WHERE id = ?", recordID,
+	).Scan(&name, &maxPerOrder, &stock)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "record not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	if exceedsMaxPerOrder(maxPerOrder, payload.Quantity) {
+		respondWithError(w, http.StatusConflict, "quantity exceeds the per-order limit for "+name)
+		return
+	}
+	if payload.Quantity > stock {
+		respondWithJSON(w, http.StatusConflict, map[string]interface{}{
+			"error":     "insufficient stock",
+			"available": stock,
+		})
+		return
+	}
+
+	res, err := database.DB.Exec(
+		"UPDATE cart_items SET quantity = ? WHERE user_id = ? AND record_id = ?",
+		payload.Quantity, userID, recordID,
+	)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		respondWithError(w, http.StatusNotFound, "record is not in the cart")
+		return
+	}
+
+	recordCartEvent(userID, recordID, "update", payload.Quantity)
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "updated"})
+}
+
+// ClearCartHandler empties the authenticated user's cart in one statement
+// and reports how many lines were removed. An already-empty cart is not an
+// error — clearing it is idempotent and just reports zero.
+func ClearCartHandler(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r)
+
+	res, err := database.DB.Exec("DELETE FROM cart_items WHERE user_id = ?", userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	removed, _ := res.RowsAffected()
+
+	recordCartEvent(userID, 0, "clear", 0)
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status":  "cleared",
+		"removed": removed,
+	})
+}
+
+// RemoveFromCartHandler removes one record from the cart entirely.
+func RemoveFromCartHandler(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r)
+
+	recordID, err := strconv.Atoi(mux.Vars(r)["recordId"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid record id")
+		return
+	}
+
+	res, err := database.DB.Exec(
+		"DELETE FROM cart_items WHERE user_id = ? AND record_id = ?", userID, recordID,
+	)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		respondWithError(w, http.StatusNotFound, "record is not in the cart")
+		return
+	}
+
+	recordCartEvent(userID, recordID, "remove", 0)
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "removed"})
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"backend/database"
+)
+
+// recordCartEvent logs one cart mutation for abandoned-cart analytics.
+// Failures are logged, not surfaced: analytics must never break the cart.
+func recordCartEvent(userID, recordID int, action string, quantity int) {
+	if _, err := database.DB.Exec(
+		"INSERT INTO cart_events (user_id, record_id, action, quantity) VALUES (?, ?, ?, ?)",
+		userID, recordID, action, quantity,
+	); err != nil {
+		log.Printf("cart event write failed: %v", err)
+	}
+}
+
+type cartActivityDay struct {
+	Day     string `json:"day"`
+	Adds    int    `json:"adds"`
+	Updates int    `json:"updates"`
+	Removes int    `json:"removes"`
+}
+
+// GetCartActivityHandler aggregates cart events per day — adds vs updates vs
+// removes — optionally bounded by from/to dates (inclusive).
+func GetCartActivityHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	where := "WHERE 1=1"
+	args := []interface{}{}
+	if from := q.Get("from"); from != "" {
+		if _, err := time.Parse("2006-01-02", from); err != nil {
+			respondWithError(w, http.StatusBadRequest, "from must be in YYYY-MM-DD format")
+			return
+		}
+		where += " AND created_at >= ?"
+		args = append(args, from)
+	}
+	if to := q.Get("to"); to != "" {
+		day, err := time.Parse("2006-01-02", to)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "to must be in YYYY-MM-DD format")
+			return
+		}
+		where += " AND created_at < ?"
+		args = append(args, day.AddDate(0, 0, 1).Format("2006-01-02"))
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT substr(created_at, 1, 10) AS day,
+		       SUM(CASE WHEN action = 'add' THEN 1 ELSE 0 END),
+		       SUM(CASE WHEN action = 'update' THEN 1 ELSE 0 END),
+		       SUM(CASE WHEN action = 'remove' THEN 1 ELSE 0 END)
+		FROM cart_events `+where+`
+		GROUP BY day
+		ORDER BY day`, args...)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	defer rows.Close()
+
+	days := []cartActivityDay{}
+	for rows.Next() {
+		var d cartActivityDay
+		if err := rows.Scan(&d.Day, &d.Adds, &d.Updates, &d.Removes); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		days = append(days, d)
+	}
+
+	respondWithJSON(w, http.StatusOK, days)
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+)
+
+// publicConfig is the subset of server configuration the frontend may see.
+// Secrets — the JWT secret above all — must never appear here.
+type publicConfig struct {
+	Currency         string  `json:"currency"`
+	TaxRate          float64 `json:"taxRate"`
+	MinOrderTotal    float64 `json:"minOrderTotal"`
+	DefaultPageSize  int     `json:"defaultPageSize"`
+	MaxPageSize      int     `json:"maxPageSize"`
+	RegistrationOpen bool    `json:"registrationOpen"`
+}
+
+// registrationOpen reports whether public signups are accepted. Set
+// REGISTRATION_CLOSED=1 to shut them off after initial setup; login is
+// unaffected either way.
+var registrationOpen = os.Getenv("REGISTRATION_CLOSED") != "1"
+
+func publicConfigFromEnv() publicConfig {
+	cfg := publicConfig{
+		Currency:         "RUB",
+		DefaultPageSize:  defaultPageSize,
+		MaxPageSize:      maxPageSize,
+		RegistrationOpen: registrationOpen,
+	}
+	if v := os.Getenv("CURRENCY"); v != "" {
+		cfg.Currency = v
+	}
+	if v, err := strconv.ParseFloat(os.Getenv("TAX_RATE"), 64); err == nil && v >= 0 {
+		cfg.TaxRate = v
+	}
+	if v, err := strconv.ParseFloat(os.Getenv("MIN_ORDER_TOTAL"), 64); err == nil && v >= 0 {
+		cfg.MinOrderTotal = v
+	}
+	return cfg
+}
+
+// GetConfigHandler returns the public server configuration so the SPA can
+// adapt to a deployment without a rebuild.
+func GetConfigHandler(w http.ResponseWriter, r *http.Request) {
+	respondWithJSON(w, http.StatusOK, publicConfigFromEnv())
+}
@@ -0,0 +1,500 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"backend/database"
+	"backend/models"
+
+	"github.com/gorilla/mux"
+)
+
+type ensemblePayload struct {
+	Name string `json:"name"`
+}
+
+// CreateEnsembleHandler adds an ensemble.
+func CreateEnsembleHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ensemblePayload
+	if err := decodeJSONBody(r, &payload); err != nil {
+		if err == errEmptyBody {
+			respondWithError(w, http.StatusBadRequest, "request body is required")
+			return
+		}
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if payload.Name == "" {
+		respondWithError(w, http.StatusBadRequest, "name is required")
+		return
+	}
+	if err := validateName("name", payload.Name); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	id, err := database.DB.Insert("INSERT INTO ensembles (name) VALUES (?)", sanitizeText(payload.Name))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	recordAudit(r, "create", "ensemble", int(id))
+	respondWithJSON(w, http.StatusCreated, map[string]int{"id": int(id)})
+}
+
+// UpdateEnsembleHandler renames an ensemble. The name must not collide with
+// another ensemble; the ensembles table carries no UNIQUE constraint on name,
+// so the collision check is an explicit query.
+func UpdateEnsembleHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid ensemble id")
+		return
+	}
+
+	var payload ensemblePayload
+	if err := decodeJSONBody(r, &payload); err != nil {
+		if err == errEmptyBody {
+			respondWithError(w, http.StatusBadRequest, "request body is required")
+			return
+		}
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if payload.Name == "" {
+		respondWithError(w, http.StatusBadRequest, "name is required")
+		return
+	}
+	if err := validateName("name", payload.Name); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	name := sanitizeText(payload.Name)
+
+	var count int
+	if err := database.DB.QueryRow(
+		"SELECT COUNT(*) FROM ensembles WHERE name = ? AND id != ?", name, id,
+	).Scan(&count); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	if count > 0 {
+		respondWithError(w, http.StatusConflict, "an ensemble with this name already exists")
+		return
+	}
+
+	res, err := database.DB.Exec("UPDATE ensembles SET name = ? WHERE id = ?", name, id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		respondWithError(w, http.StatusNotFound, "ensemble not found")
+		return
+	}
+
+	recordAudit(r, "update", "ensemble", id)
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "updated"})
+}
+
+// DeleteEnsembleHandler removes an ensemble outright. Its membership rows go
+// with it (ensemble_musicians cascades), while its tracks survive with
+// ensemble_id set to NULL by the foreign key; the response reports how many
+// tracks were detached that way so the caller knows what just lost its
+// artist. Archiving remains the right call when the ensemble might come back.
+func DeleteEnsembleHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid ensemble id")
+		return
+	}
+
+	var detached int
+	if err := database.DB.QueryRow(
+		"SELECT COUNT(*) FROM tracks WHERE ensemble_id = ?", id,
+	).Scan(&detached); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	res, err := database.DB.Exec("DELETE FROM ensembles WHERE id = ?", id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		respondWithError(w, http.StatusNotFound, "ensemble not found")
+		return
+	}
+
+	recordAudit(r, "delete", "ensemble", id)
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status":         "deleted",
+		"detachedTracks": detached,
+	})
+}
+
+// GetEnsemblesHandler lists ensembles. Archived ones are excluded unless
+// includeArchived=true is passed.
+func GetEnsemblesHandler(w http.ResponseWriter, r *http.Request) {
+	query := "SELECT id, name, archived FROM ensembles WHERE archived = 0 ORDER BY id"
+	if r.URL.Query().Get("includeArchived") == "true" {
+		query = "SELECT id, name, archived FROM ensembles ORDER BY id"
+	}
+
+	rows, err := database.DB.Query(query)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	defer rows.Close()
+
+	ensembles := []models.Ensemble{}
+	for rows.Next() {
+		var e models.Ensemble
+		if err := rows.Scan(&e.ID, &e.Name, &e.Archived); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		ensembles = append(ensembles, e)
+	}
+
+	respondWithJSON(w, http.StatusOK, ensembles)
+}
+
+// GetEnsembleMusiciansHandler lists an ensemble's roster through the
+// ensemble_musicians join table. A missing ensemble is 404; an ensemble with
+// no members is an empty array, so the two cases stay distinguishable.
+func GetEnsembleMusiciansHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid ensemble id")
+		return
+	}
+
+	var exists int
+	if err := database.DB.QueryRow("SELECT COUNT(*) FROM ensembles WHERE id = ?", id).Scan(&exists); err != nil || exists == 0 {
+		respondWithError(w, http.StatusNotFound, "ensemble not found")
+		return
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT m.id, m.name
+		FROM ensemble_musicians em
+		JOIN musicians m ON m.id = em.musician_id
+		WHERE em.ensemble_id = ?
+		ORDER BY m.id`, id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	defer rows.Close()
+
+	musicians := []models.Musician{}
+	for rows.Next() {
+		var m models.Musician
+		if err := rows.Scan(&m.ID, &m.Name); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		musicians = append(musicians, m)
+	}
+
+	respondWithJSON(w, http.StatusOK, musicians)
+}
+
+// setEnsembleArchived flips the archived flag, preserving the ensemble's
+// tracks and records either way.
+func setEnsembleArchived(w http.ResponseWriter, r *http.Request, archived bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid ensemble id")
+		return
+	}
+
+	res, err := database.DB.Exec("UPDATE ensembles SET archived = ? WHERE id = ?", archived, id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		respondWithError(w, http.StatusNotFound, "ensemble not found")
+		return
+	}
+
+	status := "unarchived"
+	if archived {
+		status = "archived"
+	}
+	recordAudit(r, "update", "ensemble", id)
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": status})
+}
+
+// ArchiveEnsembleHandler hides an ensemble from active listings.
+func ArchiveEnsembleHandler(w http.ResponseWriter, r *http.Request) {
+	setEnsembleArchived(w, r, true)
+}
+
+// UnarchiveEnsembleHandler returns an archived ensemble to active listings.
+func UnarchiveEnsembleHandler(w http.ResponseWriter, r *http.Request) {
+	setEnsembleArchived(w, r, false)
+}
+
+type bulkMembersPayload struct {
+	MusicianIDs []int `json:"musicianIds"`
+}
+
+type memberAssignResult struct {
+	MusicianID int    `json:"musicianId"`
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+}
+
+// BulkAssignMembersHandler adds a batch of musicians to an ensemble's roster
+// in one transaction. Unknown musician ids are skipped and reported per
+// musician; ones already on the roster count as assigned.
+func BulkAssignMembersHandler(w http.ResponseWriter, r *http.Request) {
+	ensembleID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid ensemble id")
+		return
+	}
+
+	var payload bulkMembersPayload
+	if err := decodeJSONBody(r, &payload); err != nil {
+		if err == errEmptyBody {
+			respondWithError(w, http.StatusBadRequest, "request body is required")
+			return
+		}
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if len(payload.MusicianIDs) == 0 {
+		respondWithError(w, http.StatusBadRequest, "at least one musician is required")
+		return
+	}
+
+	var exists int
+	if err := database.DB.QueryRow("SELECT COUNT(*) FROM ensembles WHERE id = ?", ensembleID).Scan(&exists); err != nil || exists == 0 {
+		respondWithError(w, http.StatusNotFound, "ensemble not found")
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	defer tx.Rollback()
+
+	results := make([]memberAssignResult, 0, len(payload.MusicianIDs))
+	assigned := 0
+	for _, musicianID := range payload.MusicianIDs {
+		var known int
+		if err := tx.QueryRow("SELECT COUNT(*) FROM musicians WHERE id = ?", musicianID).Scan(&known); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		if known == 0 {
+			results = append(results, memberAssignResult{MusicianID: musicianID, Status: "skipped", Error: "musician not found"})
+			continue
+		}
+		if _, err := tx.Exec(
+			"INSERT INTO ensemble_musicians (ensemble_id, musician_id) VALUES (?, ?) ON CONFLICT DO NOTHING",
+			ensembleID, musicianID,
+		); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		results = append(results, memberAssignResult{MusicianID: musicianID, Status: "assigned"})
+		assigned++
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	if assigned > 0 {
+		recordAudit(r, "update", "ensemble", ensembleID)
+	}
+	respondWithJSON(w, http.StatusOK, results)
+}
+
+type mergeEnsemblesPayload struct {
+	SourceID int `json:"sourceId"`
+	TargetID int `json:"targetId"`
+}
+
+// MergeEnsemblesHandler folds the source ensemble into the target: tracks
+// and roster members are reassigned, then the source is deleted, all in one
+// transaction. A self-merge is rejected with 400 before anything is touched,
+// so scripted chains of merges can never delete the only copy.
+func MergeEnsemblesHandler(w http.ResponseWriter, r *http.Request) {
+	var payload mergeEnsemblesPayload
+	if err := decodeJSONBody(r, &payload); err != nil {
+		if err == errEmptyBody {
+			respondWithError(w, http.StatusBadRequest, "request body is required")
+			return
+		}
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if payload.SourceID == payload.TargetID {
+		respondWithError(w, http.StatusBadRequest, "source and target must be different ensembles")
+		return
+	}
+
+	for _, id := range []int{payload.SourceID, payload.TargetID} {
+		var exists int
+		if err := database.DB.QueryRow("SELECT COUNT(*) FROM ensembles WHERE id = ?", id).Scan(&exists); err != nil || exists == 0 {
+			respondWithError(w, http.StatusNotFound, "ensemble not found")
+			return
+		}
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(
+		"UPDATE tracks SET ensemble_id = ? WHERE ensemble_id = ?", payload.TargetID, payload.SourceID,
+	); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO ensemble_musicians (ensemble_id, musician_id)
+		SELECT ?, musician_id FROM ensemble_musicians WHERE ensemble_id = ?
+		ON CONFLICT DO NOTHING`,
+		payload.TargetID, payload.SourceID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	if _, err := tx.Exec("DELETE FROM ensemble_musicians WHERE ensemble_id = ?", payload.SourceID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	if _, err := tx.Exec("DELETE FROM ensembles WHERE id = ?", payload.SourceID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	bumpCatalogVersion()
+	recordAudit(r, "merge", "ensemble", payload.SourceID)
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "merged"})
+}
+
+type ensembleTrackCount struct {
+	EnsembleID int    `json:"ensemble_id"`
+	Name       string `json:"name"`
+	TrackCount int    `json:"track_count"`
+}
+
+// GetEnsembleTrackCountsHandler returns every ensemble with its track count
+// in one grouped query, so the admin dashboard does not need a call per
+// ensemble. Ensembles without tracks are included with a count of 0.
+func GetEnsembleTrackCountsHandler(w http.ResponseWriter, r *http.Request) {
+	rows, err := database.DB.Query(`
+		SELECT e.id, e.name, COUNT(t.id) AS track_count
+		FROM ensembles e
+		LEFT JOIN tracks t ON t.ensemble_id = e.id
+		GROUP BY e.id
+		ORDER BY e.id`)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	defer rows.Close()
+
+	counts := []ensembleTrackCount{}
+	for rows.Next() {
+		var c ensembleTrackCount
+		if err := rows.Scan(&c.EnsembleID, &c.Name, &c.TrackCount); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		counts = append(counts, c)
+	}
+
+	respondWithJSON(w, http.StatusOK, counts)
+}
+
+// GetEnsembleTrackCountHandler returns how many tracks an ensemble owns.
+func GetEnsembleTrackCountHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid ensemble id")
+		return
+	}
+
+	var exists int
+	if err := database.DB.QueryRow("SELECT COUNT(*) FROM ensembles WHERE id = ?", id).Scan(&exists); err != nil || exists == 0 {
+		respondWithError(w, http.StatusNotFound, "ensemble not found")
+		return
+	}
+
+	var count int
+	if err := database.DB.QueryRow("SELECT COUNT(*) FROM tracks WHERE ensemble_id = ?", id).Scan(&count); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]int{"ensemble_id": id, "track_count": count})
+}
+
+// GetEnsembleTracksHandler lists an ensemble's tracks in the shared
+// pagination envelope, complementing the count endpoint for the ensemble
+// detail view.
+func GetEnsembleTracksHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid ensemble id")
+		return
+	}
+
+	var exists int
+	if err := database.DB.QueryRow("SELECT COUNT(*) FROM ensembles WHERE id = ?", id).Scan(&exists); err != nil || exists == 0 {
+		respondWithError(w, http.StatusNotFound, "ensemble not found")
+		return
+	}
+
+	var total int
+	if err := database.DB.QueryRow("SELECT COUNT(*) FROM tracks WHERE ensemble_id = ?", id).Scan(&total); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	page, pageSize := parsePagination(r)
+	rows, err := database.DB.Query(
+		"SELECT id, name, duration, musician_id, ensemble_id FROM tracks WHERE ensemble_id = ? ORDER BY id LIMIT ? OFFSET ?",
+		id, pageSize, (page-1)*pageSize,
+	)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	defer rows.Close()
+
+	tracks := []models.Track{}
+	for rows.Next() {
+		var t models.Track
+		if err := rows.Scan(&t.ID, &t.Name, &t.Duration, &t.MusicianID, &t.EnsembleID); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		tracks = append(tracks, t)
+	}
+
+	respondWithJSON(w, http.StatusOK, models.NewPage(tracks, total, page, pageSize))
+}
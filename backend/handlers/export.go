@@ -0,0 +1,377 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"backend/database"
+)
+
+// exportTables lists what goes into the full-catalog ZIP: each entry becomes
+// one CSV named after the table, with the query's columns as the header row.
+// Every value is formatted with %v, so numeric and nullable columns come out
+// as plain text.
+var exportTables = []struct {
+	name  string
+	query string
+}{
+	{"records", "SELECT id, name, price, stock, release_date, date_precision, label, genre, sold_last_year, sold_current_year, weight_grams, max_per_order, archived FROM records ORDER BY id"},
+	{"tracks", "SELECT id, name, duration, musician_id, ensemble_id FROM tracks ORDER BY id"},
+	{"musicians", "SELECT id, name FROM musicians ORDER BY id"},
+	{"ensembles", "SELECT id, name, archived FROM ensembles ORDER BY id"},
+	{"record_tracks", "SELECT record_id, track_id FROM record_tracks ORDER BY record_id, track_id"},
+}
+
+// ExportFullCatalogHandler streams a ZIP of per-table CSVs — records,
+// tracks, musicians, ensembles and their links — as a portable backup of the
+// whole catalog. Both the ZIP and each CSV are written straight to the
+// response, so nothing is buffered in memory regardless of catalog size.
+func ExportFullCatalogHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="catalog.zip"`)
+
+	zw := zip.NewWriter(w)
+	defer zw.Close()
+
+	for _, table := range exportTables {
+		if err := exportTableCSV(zw, table.name, table.query); err != nil {
+			// Headers are already sent, so an error can only be logged via
+			// a truncated archive; aborting keeps it from looking complete.
+			return
+		}
+	}
+}
+
+// maxImportZipBytes caps the size of an uploaded backup archive.
+const maxImportZipBytes = 64 << 20
+
+// csvTable is one parsed CSV entry: the header and the data rows, with a
+// lookup from column name to index so the importer is not coupled to column
+// order.
+type csvTable struct {
+	cols map[string]int
+	rows [][]string
+}
+
+func (t *csvTable) get(row []string, col string) string {
+	i, ok := t.cols[col]
+	if !ok || i >= len(row) {
+		return ""
+	}
+	return row[i]
+}
+
+// importCounts reports what happened to one table during an import.
+type importCounts struct {
+	Imported    int `json:"imported"`
+	Skipped     int `json:"skipped"`
+	Overwritten int `json:"overwritten"`
+}
+
+// ImportFullCatalogHandler restores a catalog from the ZIP produced by the
+// export endpoint. The archive must be self-contained: every owner id a
+// track names must appear in the archive's musicians or ensembles CSV, and
+// every record_tracks pair must reference rows from the archive — violations
+// fail the whole upload with 400 before anything is written. Rows whose ids
+// already exist are skipped by default, or updated in place with
+// ?overwrite=true; ?dryRun=true runs the full import inside a transaction
+// and rolls it back, returning the same per-table report the real import
+// would. Everything runs in one transaction either way.
+func ImportFullCatalogHandler(w http.ResponseWriter, r *http.Request) {
+	overwrite := r.URL.Query().Get("overwrite") == "true"
+	dryRun := r.URL.Query().Get("dryRun") == "true"
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxImportZipBytes))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "could not read request body")
+		return
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid ZIP archive")
+		return
+	}
+
+	tables := map[string]*csvTable{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "invalid ZIP archive")
+			return
+		}
+		all, err := csv.NewReader(rc).ReadAll()
+		rc.Close()
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "invalid CSV in "+f.Name)
+			return
+		}
+		if len(all) == 0 {
+			respondWithError(w, http.StatusBadRequest, f.Name+" is missing its header row")
+			return
+		}
+		cols := map[string]int{}
+		for i, c := range all[0] {
+			cols[c] = i
+		}
+		tables[strings.TrimSuffix(f.Name, ".csv")] = &csvTable{cols: cols, rows: all[1:]}
+	}
+	for _, t := range exportTables {
+		if tables[t.name] == nil {
+			respondWithError(w, http.StatusBadRequest, "archive is missing "+t.name+".csv")
+			return
+		}
+	}
+
+	if msg := validateImportIntegrity(tables); msg != "" {
+		respondWithError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	defer tx.Rollback()
+
+	report := map[string]*importCounts{}
+	importRow := func(table string, id string, insert, update func() error) error {
+		counts := report[table]
+		if counts == nil {
+			counts = &importCounts{}
+			report[table] = counts
+		}
+		var exists int
+		if err := tx.QueryRow("SELECT COUNT(*) FROM "+table+" WHERE id = ?", id).Scan(&exists); err != nil {
+			return err
+		}
+		if exists > 0 {
+			if !overwrite {
+				counts.Skipped++
+				return nil
+			}
+			counts.Overwritten++
+			return update()
+		}
+		counts.Imported++
+		return insert()
+	}
+
+	// Parents first, so the link table's references resolve.
+	musicians := tables["musicians"]
+	for _, row := range musicians.rows {
+		id, name := musicians.get(row, "id"), musicians.get(row, "name")
+		if err := importRow("musicians", id,
+			func() error {
+				_, err := tx.Exec("INSERT INTO musicians (id, name) VALUES (?, ?)", id, name)
+				return err
+			},
+			func() error {
+				_, err := tx.Exec("UPDATE musicians SET name = ? WHERE id = ?", name, id)
+				return err
+			}); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+	}
+	ensembles := tables["ensembles"]
+	for _, row := range ensembles.rows {
+		id, name, archived := ensembles.get(row, "id"), ensembles.get(row, "name"), ensembles.get(row, "archived")
+		if err := importRow("ensembles", id,
+			func() error {
+				_, err := tx.Exec("INSERT INTO ensembles (id, name, archived) VALUES (?, ?, ?)", id, name, archived)
+				return err
+			},
+			func() error {
+				_, err := tx.Exec("UPDATE ensembles SET name = ?, archived = ? WHERE id = ?", name, archived, id)
+				return err
+			}); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+	}
+	tracks := tables["tracks"]
+	for _, row := range tracks.rows {
+		id, name, duration := tracks.get(row, "id"), tracks.get(row, "name"), tracks.get(row, "duration")
+		musicianID := nullableID(tracks.get(row, "musician_id"))
+		ensembleID := nullableID(tracks.get(row, "ensemble_id"))
+		if err := importRow("tracks", id,
+			func() error {
+				_, err := tx.Exec(
+					"INSERT INTO tracks (id, name, duration, musician_id, ensemble_id) VALUES (?, ?, ?, ?, ?)",
+					id, name, duration, musicianID, ensembleID)
+				return err
+			},
+			func() error {
+				_, err := tx.Exec(
+					"UPDATE tracks SET name = ?, duration = ?, musician_id = ?, ensemble_id = ? WHERE id = ?",
+					name, duration, musicianID, ensembleID, id)
+				return err
+			}); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+	}
+	records := tables["records"]
+	for _, row := range records.rows {
+		id := records.get(row, "id")
+		// Archives written before the genre column simply have no such CSV
+		// column; get returns "" for those and the record imports without a
+		// genre.
+		args := []interface{}{
+			records.get(row, "name"), records.get(row, "price"), records.get(row, "stock"),
+			records.get(row, "release_date"), records.get(row, "date_precision"), records.get(row, "label"),
+			records.get(row, "genre"), records.get(row, "sold_last_year"), records.get(row, "sold_current_year"),
+			records.get(row, "weight_grams"), records.get(row, "max_per_order"), records.get(row, "archived"),
+		}
+		if err := importRow("records", id,
+			func() error {
+				_, err := tx.Exec(
+					"INSERT INTO records (id, name, price, stock, release_date, date_precision, label, genre, sold_last_year, sold_current_year, weight_grams, max_per_order, archived) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+					append([]interface{}{id}, args...)...)
+				return err
+			},
+			func() error {
+				_, err := tx.Exec(
+					"UPDATE records SET name = ?, price = ?, stock = ?, release_date = ?, date_precision = ?, label = ?, genre = ?, sold_last_year = ?, sold_current_year = ?, weight_grams = ?, max_per_order = ?, archived = ? WHERE id = ?",
+					append(args, id)...)
+				return err
+			}); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+	}
+	links := tables["record_tracks"]
+	linkCounts := &importCounts{}
+	report["record_tracks"] = linkCounts
+	for _, row := range links.rows {
+		res, err := tx.Exec(
+			"INSERT INTO record_tracks (record_id, track_id) VALUES (?, ?) ON CONFLICT DO NOTHING",
+			links.get(row, "record_id"), links.get(row, "track_id"))
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			linkCounts.Imported++
+		} else {
+			linkCounts.Skipped++
+		}
+	}
+
+	if !dryRun {
+		if err := tx.Commit(); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		bumpCatalogVersion()
+		recordAudit(r, "import", "catalog", 0)
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{"dryRun": dryRun, "tables": report})
+}
+
+// nullableID maps an empty CSV cell back to NULL for optional foreign keys.
+func nullableID(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// validateImportIntegrity checks that the archive is internally consistent
+// before anything touches the database; a non-empty return is the 400
+// message.
+func validateImportIntegrity(tables map[string]*csvTable) string {
+	idSet := func(name, col string) map[string]bool {
+		t := tables[name]
+		set := make(map[string]bool, len(t.rows))
+		for _, row := range t.rows {
+			set[t.get(row, col)] = true
+		}
+		return set
+	}
+	musicianIDs := idSet("musicians", "id")
+	ensembleIDs := idSet("ensembles", "id")
+	trackIDs := idSet("tracks", "id")
+	recordIDs := idSet("records", "id")
+
+	tracks := tables["tracks"]
+	for _, row := range tracks.rows {
+		if id := tracks.get(row, "musician_id"); id != "" && !musicianIDs[id] {
+			return "track " + tracks.get(row, "id") + " references unknown musician " + id
+		}
+		if id := tracks.get(row, "ensemble_id"); id != "" && !ensembleIDs[id] {
+			return "track " + tracks.get(row, "id") + " references unknown ensemble " + id
+		}
+	}
+	links := tables["record_tracks"]
+	for _, row := range links.rows {
+		if id := links.get(row, "record_id"); !recordIDs[id] {
+			return "record_tracks references unknown record " + id
+		}
+		if id := links.get(row, "track_id"); !trackIDs[id] {
+			return "record_tracks references unknown track " + id
+		}
+	}
+	return ""
+}
+
+// exportTableCSV adds one table's rows as a CSV entry in the archive.
+func exportTableCSV(zw *zip.Writer, name, query string) error {
+	entry, err := zw.Create(name + ".csv")
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(entry)
+
+	rows, err := database.DB.Query(query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	if err := cw.Write(columns); err != nil {
+		return err
+	}
+
+	values := make([]interface{}, len(columns))
+	ptrs := make([]interface{}, len(columns))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	record := make([]string, len(columns))
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return err
+		}
+		for i, v := range values {
+			switch val := v.(type) {
+			case nil:
+				record[i] = ""
+			case []byte:
+				record[i] = string(val)
+			default:
+				record[i] = fmt.Sprintf("%v", val)
+			}
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
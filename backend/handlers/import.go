@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"io"
+	"net/http"
+	"strconv"
+
+	"backend/database"
+)
+
+// recordImportRow is one parsed CSV line with its validation outcome. Rows
+// with a non-empty Error are reported and skipped by the import.
+type recordImportRow struct {
+	Line    int    `json:"line"`
+	Name    string `json:"name"`
+	ID      int    `json:"id,omitempty"`
+	Error   string `json:"error,omitempty"`
+	payload recordPayload
+}
+
+// parseRecordsCSV reads a catalog CSV with the header
+// name,price,stock,release_date,label,sold_last_year,weight_grams and
+// validates every row the same way CreateRecordHandler does.
+func parseRecordsCSV(reader io.Reader) ([]recordImportRow, error) {
+	cr := csv.NewReader(reader)
+	cr.FieldsPerRecord = 7
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, err
+	}
+	_ = header
+
+	rows := []recordImportRow{}
+	line := 1
+	for {
+		fields, err := cr.Read()
+		line++
+		if err == io.EOF {
+			break
+		}
+		row := recordImportRow{Line: line}
+		if err != nil {
+			row.Error = "malformed CSV row"
+			rows = append(rows, row)
+			continue
+		}
+
+		row.Name = fields[0]
+		row.payload.Name = fields[0]
+		if row.payload.Name == "" {
+			row.Error = "name is required"
+			rows = append(rows, row)
+			continue
+		}
+		if err := validateName("name", row.payload.Name); err != nil {
+			row.Error = err.Error()
+			rows = append(rows, row)
+			continue
+		}
+		if row.payload.Price, err = strconv.ParseFloat(fields[1], 64); err != nil || row.payload.Price < 0 {
+			row.Error = "invalid price"
+			rows = append(rows, row)
+			continue
+		}
+		if row.payload.Stock, err = strconv.Atoi(fields[2]); err != nil || row.payload.Stock < 0 {
+			row.Error = "invalid stock"
+			rows = append(rows, row)
+			continue
+		}
+		releaseDate, precision, ok := normalizeReleaseDate(fields[3])
+		if !ok {
+			row.Error = "release_date must be in YYYY-MM-DD or YYYY format"
+			rows = append(rows, row)
+			continue
+		}
+		row.payload.ReleaseDate = releaseDate
+		row.payload.datePrecision = precision
+		row.payload.Label = fields[4]
+		if row.payload.SoldLastYear, err = strconv.Atoi(fields[5]); err != nil || row.payload.SoldLastYear < 0 {
+			row.Error = "invalid sold_last_year"
+			rows = append(rows, row)
+			continue
+		}
+		if row.payload.WeightGrams, err = strconv.Atoi(fields[6]); err != nil || row.payload.WeightGrams < 0 {
+			row.Error = "invalid weight_grams"
+			rows = append(rows, row)
+			continue
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+type importPreview struct {
+	Rows    []recordImportRow `json:"rows"`
+	Valid   int               `json:"valid"`
+	Invalid int               `json:"invalid"`
+}
+
+func summarizeImportRows(rows []recordImportRow) importPreview {
+	preview := importPreview{Rows: rows}
+	for _, row := range rows {
+		if row.Error == "" {
+			preview.Valid++
+		} else {
+			preview.Invalid++
+		}
+	}
+	return preview
+}
+
+// PreviewRecordsImportHandler parses and validates a CSV catalog upload and
+// reports the per-row outcome a real import would produce, without writing
+// anything, so admins can fix the file before committing.
+func PreviewRecordsImportHandler(w http.ResponseWriter, r *http.Request) {
+	rows, err := parseRecordsCSV(r.Body)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid CSV: "+err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, summarizeImportRows(rows))
+}
+
+// ImportRecordsHandler performs the CSV import the preview endpoint
+// validates: valid rows are inserted in one transaction and reported with
+// their new ids, invalid rows are reported with their line number and
+// skipped. With ?atomic=true any invalid row fails the whole upload with 400
+// and the same per-row report, so nothing is written from a flawed file.
+func ImportRecordsHandler(w http.ResponseWriter, r *http.Request) {
+	atomic := r.URL.Query().Get("atomic") == "true"
+
+	rows, err := parseRecordsCSV(r.Body)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid CSV: "+err.Error())
+		return
+	}
+	if atomic && summarizeImportRows(rows).Invalid > 0 {
+		respondWithJSON(w, http.StatusBadRequest, summarizeImportRows(rows))
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	defer tx.Rollback()
+
+	for i := range rows {
+		if rows[i].Error != "" {
+			continue
+		}
+		p := rows[i].payload
+		id, err := tx.Insert(
+			"INSERT INTO records (name, price, stock, release_date, date_precision, label, sold_last_year, weight_grams) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+			sanitizeText(p.Name), p.Price, p.Stock, p.ReleaseDate, p.datePrecision, sanitizeText(p.Label), p.SoldLastYear, p.WeightGrams,
+		)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		rows[i].ID = int(id)
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	summary := summarizeImportRows(rows)
+	bumpCatalogVersion()
+	recordAudit(r, "import", "record", summary.Valid)
+	respondWithJSON(w, http.StatusCreated, summary)
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"net/http"
+
+	"backend/database"
+)
+
+type orphanedRecordTrack struct {
+	RecordID int `json:"record_id"`
+	TrackID  int `json:"track_id"`
+}
+
+type danglingCartItem struct {
+	UserID   int `json:"user_id"`
+	RecordID int `json:"record_id"`
+}
+
+// integrityReport collects referential and value anomalies that imports or
+// direct DB edits can leave behind. Every list empty means the data is clean.
+type integrityReport struct {
+	TracksWithBothOwners []int                 `json:"tracksWithBothOwners"`
+	TracksWithoutOwner   []int                 `json:"tracksWithoutOwner"`
+	NegativePriceRecords []int                 `json:"negativePriceRecords"`
+	CartItemsNoRecord    []danglingCartItem    `json:"cartItemsMissingRecord"`
+	OrphanedRecordTracks []orphanedRecordTrack `json:"orphanedRecordTracks"`
+}
+
+func scanIDs(query string) ([]int, error) {
+	rows, err := database.DB.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// GetDataIntegrityHandler reports rows that violate invariants the handlers
+// normally enforce but the schema cannot: tracks owned by both a musician and
+// an ensemble or by neither, records with negative prices, cart items
+// pointing at deleted records, and record_tracks links whose record or track
+// is gone. It is a
+// read-only diagnostic for cleaning up a messy database.
+func GetDataIntegrityHandler(w http.ResponseWriter, r *http.Request) {
+	report := integrityReport{
+		CartItemsNoRecord:    []danglingCartItem{},
+		OrphanedRecordTracks: []orphanedRecordTrack{},
+	}
+
+	var err error
+	report.TracksWithBothOwners, err = scanIDs(
+		"SELECT id FROM tracks WHERE musician_id IS NOT NULL AND ensemble_id IS NOT NULL ORDER BY id")
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	report.TracksWithoutOwner, err = scanIDs(
+		"SELECT id FROM tracks WHERE musician_id IS NULL AND ensemble_id IS NULL ORDER BY id")
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	report.NegativePriceRecords, err = scanIDs("SELECT id FROM records WHERE price < 0 ORDER BY id")
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT user_id, record_id FROM cart_items
+		WHERE record_id NOT IN (SELECT id FROM records)
+		ORDER BY user_id, record_id`)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var item danglingCartItem
+		if err := rows.Scan(&item.UserID, &item.RecordID); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		report.CartItemsNoRecord = append(report.CartItemsNoRecord, item)
+	}
+
+	linkRows, err := database.DB.Query(`
+		SELECT record_id, track_id FROM record_tracks
+		WHERE record_id NOT IN (SELECT id FROM records)
+		   OR track_id NOT IN (SELECT id FROM tracks)
+		ORDER BY record_id, track_id`)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	defer linkRows.Close()
+	for linkRows.Next() {
+		var link orphanedRecordTrack
+		if err := linkRows.Scan(&link.RecordID, &link.TrackID); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		report.OrphanedRecordTracks = append(report.OrphanedRecordTracks, link)
+	}
+
+	respondWithJSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"backend/database"
+)
+
+// inventorySnapshotInterval controls how often the snapshot job runs.
+// Override with INVENTORY_SNAPSHOT_INTERVAL (seconds); the default of one
+// day matches the per-day granularity of the snapshots themselves.
+var inventorySnapshotInterval = inventorySnapshotIntervalFromEnv()
+
+func inventorySnapshotIntervalFromEnv() time.Duration {
+	if v, err := strconv.Atoi(os.Getenv("INVENTORY_SNAPSHOT_INTERVAL")); err == nil && v > 0 {
+		return time.Duration(v) * time.Second
+	}
+	return 24 * time.Hour
+}
+
+// TakeInventorySnapshot records today's total stock units and retail value.
+// The snapshot is keyed by date and upserted, so running it any number of
+// times in one day just refreshes that day's row.
+func TakeInventorySnapshot() error {
+	var units int
+	var value float64
+	if err := database.DB.QueryRow(
+		"SELECT COALESCE(SUM(stock), 0), COALESCE(SUM(stock * price), 0) FROM records",
+	).Scan(&units, &value); err != nil {
+		return err
+	}
+
+	_, err := database.DB.Exec(`
+		INSERT INTO inventory_snapshots (snapshot_date, total_units, total_value) VALUES (?, ?, ?)
+		ON CONFLICT(snapshot_date) DO UPDATE SET total_units = excluded.total_units, total_value = excluded.total_value`,
+		time.Now().UTC().Format("2006-01-02"), units, value)
+	return err
+}
+
+// StartInventorySnapshotJob takes a snapshot immediately and then keeps the
+// series current on a ticker. Failures are logged and retried on the next
+// tick; the job must never take the server down.
+func StartInventorySnapshotJob() {
+	if err := TakeInventorySnapshot(); err != nil {
+		log.Printf("inventory snapshot failed: %v", err)
+	}
+	go func() {
+		for range time.Tick(inventorySnapshotInterval) {
+			if err := TakeInventorySnapshot(); err != nil {
+				log.Printf("inventory snapshot failed: %v", err)
+			}
+		}
+	}()
+}
+
+type inventorySnapshot struct {
+	Date       string  `json:"date"`
+	TotalUnits int     `json:"total_units"`
+	TotalValue float64 `json:"total_value"`
+}
+
+// GetInventoryValueHandler returns the daily inventory value series,
+// optionally bounded by from/to dates (inclusive).
+func GetInventoryValueHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	where := "WHERE 1=1"
+	args := []interface{}{}
+	if from := q.Get("from"); from != "" {
+		if _, err := time.Parse("2006-01-02", from); err != nil {
+			respondWithError(w, http.StatusBadRequest, "from must be in YYYY-MM-DD format")
+			return
+		}
+		where += " AND snapshot_date >= ?"
+		args = append(args, from)
+	}
+	if to := q.Get("to"); to != "" {
+		if _, err := time.Parse("2006-01-02", to); err != nil {
+			respondWithError(w, http.StatusBadRequest, "to must be in YYYY-MM-DD format")
+			return
+		}
+		where += " AND snapshot_date <= ?"
+		args = append(args, to)
+	}
+
+	rows, err := database.DB.Query(
+		"SELECT snapshot_date, total_units, total_value FROM inventory_snapshots "+where+" ORDER BY snapshot_date", args...,
+	)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	defer rows.Close()
+
+	series := []inventorySnapshot{}
+	for rows.Next() {
+		var s inventorySnapshot
+		if err := rows.Scan(&s.Date, &s.TotalUnits, &s.TotalValue); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		series = append(series, s)
+	}
+
+	respondWithJSON(w, http.StatusOK, series)
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/smtp"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Mailer sends transactional mail. The concrete sender is chosen at startup:
+// a real SMTP client when SMTP_ADDR is set, otherwise a logger so mail-less
+// deployments keep working.
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+// logMailer is the no-SMTP fallback: it records what would have been sent.
+type logMailer struct{}
+
+func (logMailer) Send(to, subject, body string) error {
+	log.Printf("mail (not sent, no SMTP configured) to=%s subject=%q", to, subject)
+	return nil
+}
+
+// smtpMailer delivers through the server at addr, e.g. "localhost:25".
+type smtpMailer struct {
+	addr string
+	from string
+}
+
+func (m smtpMailer) Send(to, subject, body string) error {
+	msg := []byte("From: " + m.from + "\r\nTo: " + to + "\r\nSubject: " + subject + "\r\n\r\n" + body + "\r\n")
+	return smtp.SendMail(m.addr, nil, m.from, []string{to}, msg)
+}
+
+// errMailCircuitOpen is returned while the breaker is open, so callers fail
+// fast instead of hanging on a mail server that is known to be down.
+var errMailCircuitOpen = errors.New("mail circuit open")
+
+// mailCircuitCooldown is how long the breaker stays open before the next
+// send is allowed to probe the server again.
+const mailCircuitCooldown = time.Minute
+
+// retryMailer decorates a Mailer with per-send retries and a circuit
+// breaker: after threshold consecutive failed sends the circuit opens and
+// sends fail fast for a cooldown period. Attempts and threshold come from
+// MAIL_RETRY_ATTEMPTS and MAIL_FAILURE_THRESHOLD.
+type retryMailer struct {
+	inner     Mailer
+	attempts  int
+	threshold int
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+func (m *retryMailer) Send(to, subject, body string) error {
+	m.mu.Lock()
+	if time.Now().Before(m.openUntil) {
+		m.mu.Unlock()
+		return errMailCircuitOpen
+	}
+	m.mu.Unlock()
+
+	var err error
+	for attempt := 0; attempt < m.attempts; attempt++ {
+		if err = m.inner.Send(to, subject, body); err == nil {
+			m.mu.Lock()
+			m.failures = 0
+			m.mu.Unlock()
+			return nil
+		}
+	}
+
+	m.mu.Lock()
+	m.failures++
+	if m.failures >= m.threshold {
+		m.openUntil = time.Now().Add(mailCircuitCooldown)
+		m.failures = 0
+	}
+	m.mu.Unlock()
+	return err
+}
+
+// mailer is the shared sender used by all handlers.
+var mailer Mailer = mailerFromEnv()
+
+func mailerFromEnv() Mailer {
+	attempts := 3
+	if v, err := strconv.Atoi(os.Getenv("MAIL_RETRY_ATTEMPTS")); err == nil && v > 0 {
+		attempts = v
+	}
+	threshold := 5
+	if v, err := strconv.Atoi(os.Getenv("MAIL_FAILURE_THRESHOLD")); err == nil && v > 0 {
+		threshold = v
+	}
+
+	var inner Mailer = logMailer{}
+	if addr := os.Getenv("SMTP_ADDR"); addr != "" {
+		from := os.Getenv("SMTP_FROM")
+		if from == "" {
+			from = "noreply@localhost"
+		}
+		inner = smtpMailer{addr: addr, from: from}
+	}
+	return &retryMailer{inner: inner, attempts: attempts, threshold: threshold}
+}
+
+// sendMailAsync delivers mail off the request path. Mail is best-effort
+// everywhere it is used: a down mail server gets logged, never surfaced as an
+// error to the user-facing call.
+func sendMailAsync(to, subject, body string) {
+	go func() {
+		if err := mailer.Send(to, subject, body); err != nil {
+			log.Printf("mail to %s failed: %v", to, err)
+		}
+	}()
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"backend/database"
+)
+
+// failingMailer always fails, counts how often it was asked to send, and
+// signals every call on a channel so tests can wait for the async delivery
+// goroutines instead of racing them.
+type failingMailer struct {
+	mu    sync.Mutex
+	calls int
+	sent  chan struct{}
+}
+
+func (m *failingMailer) Send(to, subject, body string) error {
+	m.mu.Lock()
+	m.calls++
+	m.mu.Unlock()
+	if m.sent != nil {
+		m.sent <- struct{}{}
+	}
+	return errors.New("smtp is down")
+}
+
+func (m *failingMailer) callCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.calls
+}
+
+func TestRetryMailerRetriesEachSend(t *testing.T) {
+	inner := &failingMailer{}
+	m := &retryMailer{inner: inner, attempts: 3, threshold: 5}
+
+	err := m.Send("to@example.com", "s", "b")
+	if err == nil || err.Error() != "smtp is down" {
+		t.Fatalf("send against a dead server: got %v, want the inner error", err)
+	}
+	if inner.callCount() != 3 {
+		t.Fatalf("attempts per send: got %d, want 3", inner.callCount())
+	}
+}
+
+func TestRetryMailerOpensCircuitAfterThreshold(t *testing.T) {
+	inner := &failingMailer{}
+	m := &retryMailer{inner: inner, attempts: 1, threshold: 2}
+
+	for i := 0; i < 2; i++ {
+		if err := m.Send("to@example.com", "s", "b"); err == nil {
+			t.Fatalf("send %d against a dead server: got nil error", i)
+		}
+	}
+	before := inner.callCount()
+	if err := m.Send("to@example.com", "s", "b"); err != errMailCircuitOpen {
+		t.Fatalf("send with the circuit open: got %v, want errMailCircuitOpen", err)
+	}
+	if inner.callCount() != before {
+		t.Fatalf("open circuit still reached the inner mailer: %d calls, want %d", inner.callCount(), before)
+	}
+}
+
+func TestRegistrationSurvivesMailerFailure(t *testing.T) {
+	if err := database.InitDB(filepath.Join(t.TempDir(), "store.db")); err != nil {
+		t.Fatalf("init database: %v", err)
+	}
+	t.Cleanup(func() { database.DB.Close() })
+
+	// Mail is best-effort: a registration must answer 201 even when every
+	// delivery attempt fails. The global is restored only after the async
+	// send has run, so no other goroutine sees a half-swapped mailer.
+	failing := &failingMailer{sent: make(chan struct{})}
+	previous := mailer
+	mailer = failing
+
+	req := httptest.NewRequest(http.MethodPost, "/api/register",
+		strings.NewReader(`{"email":"maildown@example.com","password":"passw0rd1"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	RegisterHandler(rec, req)
+
+	// Registration sends a welcome mail and the verification mail; the
+	// global is restored only after both async goroutines have finished
+	// with it.
+	for i := 0; i < 2; i++ {
+		select {
+		case <-failing.sent:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("mail %d was never handed to the mailer", i)
+		}
+	}
+	mailer = previous
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("register with a failing mailer: got %d %s, want 201", rec.Code, rec.Body.String())
+	}
+	if strings.Contains(rec.Body.String(), "verificationToken") {
+		t.Fatalf("register response carries the verification token: %s", rec.Body.String())
+	}
+}
@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"context"
+	"mime"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// allowedOrigins is the list of origins allowed to call the API from a
+// browser, from the comma-separated ALLOWED_ORIGINS variable. Unset means no
+// CORS headers at all — same-origin clients only; "*" allows any origin.
+var allowedOrigins = allowedOriginsFromEnv()
+
+func allowedOriginsFromEnv() []string {
+	origins := []string{}
+	for _, o := range strings.Split(os.Getenv("ALLOWED_ORIGINS"), ",") {
+		if o = strings.TrimSpace(o); o != "" {
+			origins = append(origins, o)
+		}
+	}
+	return origins
+}
+
+func originAllowed(origin string) bool {
+	for _, o := range allowedOrigins {
+		if o == "*" || o == origin {
+			return true
+		}
+	}
+	return false
+}
+
+// CORSMiddleware adds the CORS response headers for allowed origins and
+// answers preflight requests. It wraps the whole router rather than being
+// registered per-subrouter, so an OPTIONS preflight — which carries no
+// Authorization header — is answered with 204 before routing or auth can
+// reject it.
+func CORSMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if origin := r.Header.Get("Origin"); origin != "" && originAllowed(origin) {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Vary", "Origin")
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+		}
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// maxBodyBytes caps how much of a request body any handler will read. 1MB
+// covers the largest legitimate JSON or CSV payload with room to spare; the
+// full-catalog ZIP import is the one exception and keeps its own cap via
+// routeBodyLimit. Override with MAX_BODY_BYTES.
+var maxBodyBytes = maxBodyBytesFromEnv()
+
+func maxBodyBytesFromEnv() int64 {
+	if v, err := strconv.ParseInt(os.Getenv("MAX_BODY_BYTES"), 10, 64); err == nil && v > 0 {
+		return v
+	}
+	return 1 << 20
+}
+
+// routeBodyLimit returns the body cap for a path. The full-catalog ZIP
+// import legitimately carries a whole backup archive, so it keeps its own
+// much larger cap; every other route gets the JSON/CSV cap.
+func routeBodyLimit(path string) int64 {
+	if strings.HasSuffix(path, "/import/full") {
+		return maxImportZipBytes
+	}
+	return maxBodyBytes
+}
+
+// BodyLimitMiddleware rejects oversize request bodies. A declared
+// Content-Length over the route's cap is answered with 413 before anything
+// is read; the body is also wrapped in http.MaxBytesReader, so a chunked
+// upload that declares no length is cut off at the same cap and fails its
+// decode instead of exhausting memory.
+func BodyLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limit := routeBodyLimit(r.URL.Path)
+		if r.ContentLength > limit {
+			respondWithError(w, http.StatusRequestEntityTooLarge, "request body too large")
+			return
+		}
+		if r.Body != nil {
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// JSONContentTypeMiddleware rejects requests that carry a body with a
+// Content-Type other than application/json (or text/csv and application/zip
+// for the catalog import endpoints). Requests without a declared content
+// type are let through so bodyless POSTs keep working; the decoder still
+// rejects them if a body turns out to be required.
+func JSONContentTypeMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch:
+			ct := r.Header.Get("Content-Type")
+			if ct != "" {
+				mediaType, _, err := mime.ParseMediaType(ct)
+				if err != nil || (mediaType != "application/json" && mediaType != "text/csv" && mediaType != "application/zip") {
+					respondWithError(w, http.StatusUnsupportedMediaType, "Content-Type must be application/json")
+					return
+				}
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// cachePolicyFor maps a request path to the Cache-Control header its route
+// group warrants. Everything user-specific — cart, orders, profile, auth and
+// the whole admin area — must never land in a shared proxy cache; the public
+// catalog and config can be cached briefly.
+func cachePolicyFor(path string) string {
+	switch {
+	case strings.Contains(path, "/cart"),
+		strings.Contains(path, "/orders"),
+		strings.Contains(path, "/profile"),
+		strings.Contains(path, "/token"),
+		strings.Contains(path, "/admin/"):
+		return "private, no-store"
+	case strings.Contains(path, "/records"), strings.Contains(path, "/config"):
+		return "public, max-age=60"
+	default:
+		return "no-cache"
+	}
+}
+
+// CacheControlMiddleware sets the Cache-Control header on GET responses
+// according to the route group, so proxies can cache the catalog but never
+// user-specific data. Responses on the publicly cacheable routes still vary
+// by Authorization — an admin's includeArchived listing is not the anonymous
+// catalog — so those carry Vary: Authorization, and an authenticated request
+// gets private, no-store outright rather than trusting every proxy to honor
+// the Vary.
+func CacheControlMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			policy := cachePolicyFor(r.URL.Path)
+			if strings.HasPrefix(policy, "public") {
+				w.Header().Add("Vary", "Authorization")
+				if r.Header.Get("Authorization") != "" {
+					policy = "private, no-store"
+				}
+			}
+			w.Header().Set("Cache-Control", policy)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// contextKey is an unexported type for the identity values AuthMiddleware
+// stores in the request context. A bare string key would collide with any
+// other package using the same literal; the private type makes the keys
+// unreachable from outside this package.
+type contextKey string
+
+const (
+	userIDKey contextKey = "userID"
+	roleKey   contextKey = "role"
+)
+
+// AuthMiddleware validates the Bearer token and stores the user identity in
+// the request context.
+func AuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		if header == "" {
+			respondWithError(w, http.StatusUnauthorized, "missing authorization header")
+			return
+		}
+		tokenString := strings.TrimPrefix(header, "Bearer ")
+
+		claims := &Claims{}
+		token, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+			return jwtSecret, nil
+		})
+		if err != nil || !token.Valid {
+			respondWithError(w, http.StatusUnauthorized, "invalid or expired token")
+			return
+		}
+		if isTokenRevoked(tokenString) {
+			respondWithError(w, http.StatusUnauthorized, "token has been revoked")
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), userIDKey, claims.UserID)
+		ctx = context.WithValue(ctx, roleKey, claims.Role)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// AdminMiddleware allows the request through only for the admin role. It must
+// run after AuthMiddleware.
+func AdminMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		role, _ := r.Context().Value(roleKey).(string)
+		if role != "admin" {
+			respondWithError(w, http.StatusForbidden, "admin access required")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requestRole returns the caller's role on routes that sit outside
+// AuthMiddleware. It prefers the role the middleware stored when present and
+// otherwise parses the Bearer token itself with the same validity and
+// revocation checks; a missing or invalid token simply means no role rather
+// than an error, since these routes serve anonymous callers too.
+func requestRole(r *http.Request) string {
+	if role, _ := r.Context().Value(roleKey).(string); role != "" {
+		return role
+	}
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return ""
+	}
+	tokenString := strings.TrimPrefix(header, "Bearer ")
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		return jwtSecret, nil
+	})
+	if err != nil || !token.Valid || isTokenRevoked(tokenString) {
+		return ""
+	}
+	return claims.Role
+}
+
+// userIDFromContext extracts the authenticated user's id set by AuthMiddleware.
+func userIDFromContext(r *http.Request) int {
+	id, _ := r.Context().Value(userIDKey).(int)
+	return id
+}
+
+// requireOwnership checks that the authenticated user owns a resource; admins
+// pass unconditionally. On failure it writes a 403 and returns false, so
+// handlers can bail with a bare return. The status is deliberately 403 rather
+// than 404: ids here are small sequential integers, so pretending the
+// resource does not exist hides nothing, and an honest status is easier to
+// debug. Any handler serving a user-scoped resource by id must call this
+// after loading the row.
+func requireOwnership(w http.ResponseWriter, r *http.Request, resourceUserID int) bool {
+	if userIDFromContext(r) == resourceUserID {
+		return true
+	}
+	if role, _ := r.Context().Value(roleKey).(string); role == "admin" {
+		return true
+	}
+	respondWithError(w, http.StatusForbidden, "you do not have access to this resource")
+	return false
+}
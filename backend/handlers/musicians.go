@@ -0,0 +1,281 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"backend/database"
+
+	"github.com/gorilla/mux"
+)
+
+type musicianPayload struct {
+	Name   string         `json:"name"`
+	Tracks []trackPayload `json:"tracks"`
+}
+
+// execer lets the insert helpers run against either the shared DB handle or
+// an open transaction.
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Insert(query string, args ...interface{}) (int64, error)
+}
+
+// insertMusician validates and inserts one musician, returning the new id.
+func insertMusician(db execer, name string) (int, error) {
+	if name == "" {
+		return 0, errors.New("name is required")
+	}
+	if err := validateName("name", name); err != nil {
+		return 0, err
+	}
+	id, err := db.Insert("INSERT INTO musicians (name) VALUES (?)", sanitizeText(name))
+	return int(id), err
+}
+
+// CreateMusicianHandler adds a musician.
+func CreateMusicianHandler(w http.ResponseWriter, r *http.Request) {
+	var payload musicianPayload
+	if err := decodeJSONBody(r, &payload); err != nil {
+		if err == errEmptyBody {
+			respondWithError(w, http.StatusBadRequest, "request body is required")
+			return
+		}
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if payload.Name == "" {
+		respondWithError(w, http.StatusBadRequest, "name is required")
+		return
+	}
+	if err := validateName("name", payload.Name); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	id, err := insertMusician(database.DB, payload.Name)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	recordAudit(r, "create", "musician", id)
+	respondWithJSON(w, http.StatusCreated, map[string]int{"id": id})
+}
+
+// musicianListing is a musician with the names of the ensembles they play
+// in, joined through ensemble_musicians.
+type musicianListing struct {
+	ID        int      `json:"id"`
+	Name      string   `json:"name"`
+	Ensembles []string `json:"ensembles"`
+}
+
+// GetMusiciansHandler lists all musicians with their ensemble names. The
+// memberships come from one join query rather than a query per musician.
+func GetMusiciansHandler(w http.ResponseWriter, r *http.Request) {
+	rows, err := database.DB.Query("SELECT id, name FROM musicians ORDER BY id")
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	defer rows.Close()
+
+	musicians := []musicianListing{}
+	byID := map[int]*musicianListing{}
+	for rows.Next() {
+		var m musicianListing
+		if err := rows.Scan(&m.ID, &m.Name); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		m.Ensembles = []string{}
+		musicians = append(musicians, m)
+	}
+	for i := range musicians {
+		byID[musicians[i].ID] = &musicians[i]
+	}
+
+	memberRows, err := database.DB.Query(`
+		SELECT em.musician_id, e.name
+		FROM ensemble_musicians em
+		JOIN ensembles e ON e.id = em.ensemble_id
+		ORDER BY em.musician_id, e.id`)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	defer memberRows.Close()
+	for memberRows.Next() {
+		var musicianID int
+		var ensembleName string
+		if err := memberRows.Scan(&musicianID, &ensembleName); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		if m, ok := byID[musicianID]; ok {
+			m.Ensembles = append(m.Ensembles, ensembleName)
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, musicians)
+}
+
+// UpdateMusicianHandler renames a musician. Ensemble membership is not
+// edited here — that goes through the bulk member assignment endpoint.
+func UpdateMusicianHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid musician id")
+		return
+	}
+
+	var payload musicianPayload
+	if err := decodeJSONBody(r, &payload); err != nil {
+		if err == errEmptyBody {
+			respondWithError(w, http.StatusBadRequest, "request body is required")
+			return
+		}
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if payload.Name == "" {
+		respondWithError(w, http.StatusBadRequest, "name is required")
+		return
+	}
+	if err := validateName("name", payload.Name); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res, err := database.DB.Exec("UPDATE musicians SET name = ? WHERE id = ?", sanitizeText(payload.Name), id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		respondWithError(w, http.StatusNotFound, "musician not found")
+		return
+	}
+
+	recordAudit(r, "update", "musician", id)
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "updated"})
+}
+
+// DeleteMusicianHandler removes a musician. Membership rows cascade away and
+// the musician's tracks survive with musician_id set to NULL — the same
+// foreign-key behavior the ensemble delete relies on — so the response
+// reports how many tracks were detached.
+func DeleteMusicianHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid musician id")
+		return
+	}
+
+	var detached int
+	if err := database.DB.QueryRow(
+		"SELECT COUNT(*) FROM tracks WHERE musician_id = ?", id,
+	).Scan(&detached); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	res, err := database.DB.Exec("DELETE FROM musicians WHERE id = ?", id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		respondWithError(w, http.StatusNotFound, "musician not found")
+		return
+	}
+
+	recordAudit(r, "delete", "musician", id)
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status":         "deleted",
+		"detachedTracks": detached,
+	})
+}
+
+type importSkip struct {
+	Index int    `json:"index"`
+	Error string `json:"error"`
+}
+
+type musicianImportResult struct {
+	Created []int        `json:"created"`
+	Skipped []importSkip `json:"skipped"`
+}
+
+// ImportMusiciansHandler inserts a batch of musicians (with optional tracks)
+// in one transaction. Invalid rows are skipped and reported; valid rows are
+// still committed.
+func ImportMusiciansHandler(w http.ResponseWriter, r *http.Request) {
+	var batch []musicianPayload
+	if err := decodeJSONBody(r, &batch); err != nil {
+		if err == errEmptyBody {
+			respondWithError(w, http.StatusBadRequest, "request body is required")
+			return
+		}
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if len(batch) == 0 {
+		respondWithError(w, http.StatusBadRequest, "at least one musician is required")
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	defer tx.Rollback()
+
+	result := musicianImportResult{Created: []int{}, Skipped: []importSkip{}}
+	for i, m := range batch {
+		invalid := ""
+		for _, t := range m.Tracks {
+			if t.Name == "" {
+				invalid = "track name is required"
+				break
+			}
+			if err := validateName("track name", t.Name); err != nil {
+				invalid = err.Error()
+				break
+			}
+		}
+		if invalid != "" {
+			result.Skipped = append(result.Skipped, importSkip{Index: i, Error: invalid})
+			continue
+		}
+
+		id, err := insertMusician(tx, m.Name)
+		if err != nil {
+			result.Skipped = append(result.Skipped, importSkip{Index: i, Error: err.Error()})
+			continue
+		}
+		for _, t := range m.Tracks {
+			if _, err := tx.Exec(
+				"INSERT INTO tracks (name, duration, musician_id) VALUES (?, ?, ?)", sanitizeText(t.Name), t.Duration, id,
+			); err != nil {
+				respondWithError(w, http.StatusInternalServerError, "database error")
+				return
+			}
+		}
+		result.Created = append(result.Created, id)
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	bumpCatalogVersion()
+	recordAudit(r, "import", "musician", len(result.Created))
+	respondWithJSON(w, http.StatusCreated, result)
+}
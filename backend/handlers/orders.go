@@ -0,0 +1,349 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"backend/database"
+	"backend/models"
+
+	"github.com/gorilla/mux"
+)
+
+// CheckoutHandler turns the user's cart into an order inside a transaction,
+// checking and decrementing stock for every line.
+func CheckoutHandler(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r)
+
+	if requireVerifiedEmail {
+		verified, err := emailVerified(userID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		if !verified {
+			respondWithError(w, http.StatusForbidden, "email verification required before checkout")
+			return
+		}
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT ci.record_id, rec.name, rec.price, rec.stock, rec.max_per_order, ci.quantity
+		FROM cart_items ci
+		JOIN records rec ON rec.id = ci.record_id
+		WHERE ci.user_id = ?`, userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	type line struct {
+		recordID    int
+		name        string
+		price       float64
+		stock       int
+		maxPerOrder int
+		quantity    int
+	}
+	lines := []line{}
+	for rows.Next() {
+		var l line
+		if err := rows.Scan(&l.recordID, &l.name, &l.price, &l.stock, &l.maxPerOrder, &l.quantity); err != nil {
+			rows.Close()
+			respondWithError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		lines = append(lines, l)
+	}
+	rows.Close()
+
+	if len(lines) == 0 {
+		respondWithError(w, http.StatusBadRequest, "cart is empty")
+		return
+	}
+
+	var total float64
+	insufficient := []int{}
+	for _, l := range lines {
+		if exceedsMaxPerOrder(l.maxPerOrder, l.quantity) {
+			respondWithError(w, http.StatusConflict, "quantity exceeds the per-order limit for "+l.name)
+			return
+		}
+		if l.quantity > l.stock {
+			insufficient = append(insufficient, l.recordID)
+		}
+		total += l.price * float64(l.quantity)
+	}
+	// All shortages are reported together so the client can fix the whole
+	// cart in one pass instead of discovering them one checkout at a time.
+	if len(insufficient) > 0 {
+		respondWithJSON(w, http.StatusConflict, map[string]interface{}{
+			"error":      "insufficient stock",
+			"record_ids": insufficient,
+		})
+		return
+	}
+
+	// A promo code is validated and consumed inside the same transaction as
+	// the order, so its usage limit cannot be oversubscribed.
+	var discount float64
+	if code := r.URL.Query().Get("promo"); code != "" {
+		p, err := lookupPromo(tx, code)
+		switch err {
+		case nil:
+		case errPromoNotFound, errPromoInactive, errPromoExhausted:
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		default:
+			respondWithError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		if err := consumePromo(tx, p); err != nil {
+			if err == errPromoExhausted {
+				respondWithError(w, http.StatusConflict, err.Error())
+				return
+			}
+			respondWithError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		discount = promoDiscount(p, total)
+		total -= discount
+	}
+
+	orderID, err := tx.Insert("INSERT INTO orders (user_id, total) VALUES (?, ?)", userID, total)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	for _, l := range lines {
+		if _, err := tx.Exec(
+			"INSERT INTO order_items (order_id, record_id, quantity, price) VALUES (?, ?, ?, ?)",
+			orderID, l.recordID, l.quantity, l.price,
+		); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		// The decrement is conditional so a concurrent checkout cannot take
+		// the same units: zero rows affected means the stock we read is gone
+		// and the whole order rolls back.
+		res, err := tx.Exec(
+			"UPDATE records SET stock = stock - ?, sold_current_year = sold_current_year + ? WHERE id = ? AND stock >= ?",
+			l.quantity, l.quantity, l.recordID, l.quantity,
+		)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			respondWithJSON(w, http.StatusConflict, map[string]interface{}{
+				"error":      "insufficient stock",
+				"record_ids": []int{l.recordID},
+			})
+			return
+		}
+	}
+
+	if _, err := tx.Exec("DELETE FROM cart_items WHERE user_id = ?", userID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, map[string]interface{}{"order_id": orderID, "total": total, "discount": discount})
+}
+
+// GetOrderHandler returns one order with its items. The order is loaded by
+// id and then gated through requireOwnership, so another user's order comes
+// back as 403.
+func GetOrderHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid order id")
+		return
+	}
+
+	var o models.Order
+	err = database.DB.QueryRow(
+		"SELECT id, user_id, created_at, total FROM orders WHERE id = ?", id,
+	).Scan(&o.ID, &o.UserID, &o.CreatedAt, &o.Total)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "order not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	if !requireOwnership(w, r, o.UserID) {
+		return
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT oi.record_id, rec.name, oi.quantity, oi.price
+		FROM order_items oi
+		JOIN records rec ON rec.id = oi.record_id
+		WHERE oi.order_id = ?`, o.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var item models.OrderItem
+		if err := rows.Scan(&item.RecordID, &item.Name, &item.Quantity, &item.Price); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		o.Items = append(o.Items, item)
+	}
+
+	respondWithJSON(w, http.StatusOK, o)
+}
+
+// adminOrderListing is an order with its owner's email attached, so the
+// fulfillment view can say whose order it is without a lookup per row.
+type adminOrderListing struct {
+	models.Order
+	UserEmail string `json:"userEmail"`
+}
+
+// GetAllOrdersHandler lists every user's orders for fulfillment, newest
+// first, with items and the owner's email, in the shared pagination
+// envelope. ?userId= narrows the list to one user. Orders carry no status
+// column in this schema, so there is no status filter to offer.
+func GetAllOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	where := ""
+	args := []interface{}{}
+	if v := r.URL.Query().Get("userId"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "invalid userId filter")
+			return
+		}
+		where = " WHERE o.user_id = ?"
+		args = append(args, id)
+	}
+
+	var total int
+	if err := database.DB.QueryRow("SELECT COUNT(*) FROM orders o"+where, args...).Scan(&total); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	page, pageSize := parsePagination(r)
+	rows, err := database.DB.Query(
+		"SELECT o.id, o.user_id, o.created_at, o.total, u.email FROM orders o JOIN users u ON u.id = o.user_id"+
+			where+" ORDER BY o.id DESC LIMIT ? OFFSET ?",
+		append(args, pageSize, (page-1)*pageSize)...,
+	)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	defer rows.Close()
+
+	orders := []adminOrderListing{}
+	for rows.Next() {
+		var o adminOrderListing
+		if err := rows.Scan(&o.ID, &o.UserID, &o.CreatedAt, &o.Total, &o.UserEmail); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		orders = append(orders, o)
+	}
+
+	for i := range orders {
+		itemRows, err := database.DB.Query(`
+			SELECT oi.record_id, rec.name, oi.quantity, oi.price
+			FROM order_items oi
+			JOIN records rec ON rec.id = oi.record_id
+			WHERE oi.order_id = ?`, orders[i].ID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		for itemRows.Next() {
+			var item models.OrderItem
+			if err := itemRows.Scan(&item.RecordID, &item.Name, &item.Quantity, &item.Price); err != nil {
+				itemRows.Close()
+				respondWithError(w, http.StatusInternalServerError, "database error")
+				return
+			}
+			orders[i].Items = append(orders[i].Items, item)
+		}
+		itemRows.Close()
+	}
+
+	respondWithJSON(w, http.StatusOK, models.NewPage(orders, total, page, pageSize))
+}
+
+// GetOrdersHandler lists the authenticated user's orders with their items,
+// in the shared pagination envelope.
+func GetOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r)
+
+	var total int
+	if err := database.DB.QueryRow("SELECT COUNT(*) FROM orders WHERE user_id = ?", userID).Scan(&total); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	page, pageSize := parsePagination(r)
+	rows, err := database.DB.Query(
+		"SELECT id, user_id, created_at, total FROM orders WHERE user_id = ? ORDER BY id DESC LIMIT ? OFFSET ?",
+		userID, pageSize, (page-1)*pageSize,
+	)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	defer rows.Close()
+
+	orders := []models.Order{}
+	for rows.Next() {
+		var o models.Order
+		if err := rows.Scan(&o.ID, &o.UserID, &o.CreatedAt, &o.Total); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		orders = append(orders, o)
+	}
+
+	for i := range orders {
+		itemRows, err := database.DB.Query(`
+			SELECT oi.record_id, rec.name, oi.quantity, oi.price
+			FROM order_items oi
+			JOIN records rec ON rec.id = oi.record_id
+			WHERE oi.order_id = ?`, orders[i].ID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		for itemRows.Next() {
+			var item models.OrderItem
+			if err := itemRows.Scan(&item.RecordID, &item.Name, &item.Quantity, &item.Price); err != nil {
+				itemRows.Close()
+				respondWithError(w, http.StatusInternalServerError, "database error")
+				return
+			}
+			orders[i].Items = append(orders[i].Items, item)
+		}
+		itemRows.Close()
+	}
+
+	respondWithJSON(w, http.StatusOK, models.NewPage(orders, total, page, pageSize))
+}
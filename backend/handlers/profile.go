@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"unicode"
+
+	"backend/database"
+	"backend/models"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// GetProfileHandler returns the authenticated user's profile.
+func GetProfileHandler(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r)
+
+	var user models.User
+	err := database.DB.QueryRow(
+		"SELECT id, email, role, wholesale_address, email_verified FROM users WHERE id = ?", userID,
+	).Scan(&user.ID, &user.Email, &user.Role, &user.WholesaleAddress, &user.EmailVerified)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "user not found")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, user)
+}
+
+// maxWholesaleAddressLen caps wholesale_address input; long enough for any
+// real address, short enough to keep garbage out.
+const maxWholesaleAddressLen = 500
+
+// validateWholesaleAddress rejects clearly invalid values — over-length
+// input and control characters — while allowing empty, so legitimate free
+// text still passes.
+func validateWholesaleAddress(addr string) error {
+	if len(addr) > maxWholesaleAddressLen {
+		return errors.New("wholesale_address is too long")
+	}
+	for _, r := range addr {
+		if unicode.IsControl(r) {
+			return errors.New("wholesale_address must not contain control characters")
+		}
+	}
+	return nil
+}
+
+type profileUpdate struct {
+	Email            *string `json:"email"`
+	WholesaleAddress *string `json:"wholesale_address"`
+}
+
+// UpdateProfileHandler updates the authenticated user's profile with partial
+// semantics: the pointer fields distinguish "omitted" from "set", so a body
+// naming only one field leaves the others untouched. It answers both PUT and
+// PATCH; an empty request body means "no changes" and simply returns the
+// current profile.
+func UpdateProfileHandler(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r)
+
+	var upd profileUpdate
+	if err := decodeJSONBody(r, &upd); err != nil && err != errEmptyBody {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if upd.Email != nil {
+		if *upd.Email == "" {
+			respondWithError(w, http.StatusBadRequest, "email cannot be empty")
+			return
+		}
+		// The same canonical form registration stores and login looks up —
+		// writing a raw mixed-case address here would lock the user out of
+		// their own account.
+		email, err := normalizeEmail(*upd.Email)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		var currentEmail string
+		if err := database.DB.QueryRow("SELECT email FROM users WHERE id = ?", userID).Scan(&currentEmail); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		if email != currentEmail {
+			// A new address has not been proven: the verified flag resets and
+			// a fresh token is issued, otherwise verifying once and switching
+			// to any address would bypass the checkout gate for good.
+			if _, err := database.DB.Exec(
+				"UPDATE users SET email = ?, email_verified = 0 WHERE id = ?", email, userID,
+			); err != nil {
+				respondWithError(w, http.StatusConflict, "user with this email already exists")
+				return
+			}
+			if _, err := database.DB.Exec("DELETE FROM email_verification_tokens WHERE user_id = ?", userID); err != nil {
+				respondWithError(w, http.StatusInternalServerError, "database error")
+				return
+			}
+			// As at registration, the token goes out only through the
+			// mailer — returning it here would let the client re-verify
+			// any address it just typed in.
+			verification, err := issueEmailVerificationToken(userID)
+			if err != nil {
+				respondWithError(w, http.StatusInternalServerError, "could not generate token")
+				return
+			}
+			sendMailAsync(email, "Verify your email", "Your verification token: "+verification)
+		}
+	}
+	if upd.WholesaleAddress != nil {
+		if err := validateWholesaleAddress(*upd.WholesaleAddress); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if _, err := database.DB.Exec("UPDATE users SET wholesale_address = ? WHERE id = ?", sanitizeText(*upd.WholesaleAddress), userID); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+	}
+
+	GetProfileHandler(w, r)
+}
+
+type accountDeletion struct {
+	Password string `json:"password"`
+}
+
+// DeleteProfileHandler removes the authenticated user's own account. The
+// current password must be re-entered as confirmation, so a stolen token
+// alone cannot destroy the account. The foreign keys clear the user's cart,
+// orders, refresh tokens and lockout state via ON DELETE CASCADE, and the
+// last-admin guard refuses to delete the only remaining administrator.
+func DeleteProfileHandler(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r)
+
+	var payload accountDeletion
+	if err := decodeJSONBody(r, &payload); err != nil {
+		if err == errEmptyBody {
+			respondWithError(w, http.StatusBadRequest, "request body is required")
+			return
+		}
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	var current string
+	if err := database.DB.QueryRow("SELECT password FROM users WHERE id = ?", userID).Scan(&current); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	if bcrypt.CompareHashAndPassword([]byte(current), []byte(payload.Password)) != nil {
+		respondWithError(w, http.StatusUnauthorized, "password is incorrect")
+		return
+	}
+
+	if err := ensureNotLastAdmin(database.DB, userID); err != nil {
+		if err == errLastAdmin {
+			respondWithError(w, http.StatusConflict, errLastAdmin.Error())
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	if _, err := database.DB.Exec("DELETE FROM users WHERE id = ?", userID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "account deleted"})
+}
+
+type passwordChange struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword"`
+}
+
+// ChangePasswordHandler replaces the authenticated user's password after
+// verifying the current one. A wrong current password is 401 — the same
+// answer a stolen token would get — and a too-short new password is 400.
+func ChangePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r)
+
+	var payload passwordChange
+	if err := decodeJSONBody(r, &payload); err != nil {
+		if err == errEmptyBody {
+			respondWithError(w, http.StatusBadRequest, "request body is required")
+			return
+		}
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if err := validatePassword(payload.NewPassword); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var current string
+	if err := database.DB.QueryRow("SELECT password FROM users WHERE id = ?", userID).Scan(&current); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	if bcrypt.CompareHashAndPassword([]byte(current), []byte(payload.CurrentPassword)) != nil {
+		respondWithError(w, http.StatusUnauthorized, "current password is incorrect")
+		return
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(payload.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not hash password")
+		return
+	}
+	if _, err := database.DB.Exec("UPDATE users SET password = ? WHERE id = ?", string(hash), userID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	// A password change is often a response to a suspected compromise, so
+	// outstanding refresh tokens die with the old password — the same
+	// invalidation the reset flow performs.
+	if _, err := database.DB.Exec("DELETE FROM refresh_tokens WHERE user_id = ?", userID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	recordAudit(r, "update", "password", userID)
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "password changed"})
+}
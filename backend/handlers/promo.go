@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"backend/database"
+)
+
+// Promo lookup failures, mapped to client-facing errors by the handlers.
+var (
+	errPromoNotFound  = errors.New("unknown promo code")
+	errPromoInactive  = errors.New("promo code is expired or not yet valid")
+	errPromoExhausted = errors.New("promo code usage limit reached")
+)
+
+type promoCode struct {
+	id         int
+	code       string
+	percentOff float64
+	fixedOff   float64
+	usageLimit int
+	usedCount  int
+}
+
+// lookupPromo fetches a promo code and checks its validity window and usage
+// limit. A zero usage_limit means unlimited.
+func lookupPromo(q rowQuerier, code string) (promoCode, error) {
+	var p promoCode
+	var active bool
+	now := time.Now().UTC().Format("2006-01-02 15:04:05")
+	err := q.QueryRow(`
+		SELECT id, code, percent_off, fixed_off, usage_limit, used_count,
+		       ? BETWEEN valid_from AND valid_to
+		FROM promo_codes WHERE code = ?`, now, code,
+	).Scan(&p.id, &p.code, &p.percentOff, &p.fixedOff, &p.usageLimit, &p.usedCount, &active)
+	if err == sql.ErrNoRows {
+		return p, errPromoNotFound
+	}
+	if err != nil {
+		return p, err
+	}
+	if !active {
+		return p, errPromoInactive
+	}
+	if p.usageLimit > 0 && p.usedCount >= p.usageLimit {
+		return p, errPromoExhausted
+	}
+	return p, nil
+}
+
+// promoDiscount computes the discount the code grants on the subtotal,
+// never exceeding the subtotal itself.
+func promoDiscount(p promoCode, subtotal float64) float64 {
+	discount := subtotal*p.percentOff/100 + p.fixedOff
+	if discount > subtotal {
+		discount = subtotal
+	}
+	return discount
+}
+
+// consumePromo burns one use of the code. The guarded UPDATE makes the usage
+// limit hold under concurrent checkouts: zero rows affected means another
+// transaction took the last use.
+func consumePromo(db execer, p promoCode) error {
+	res, err := db.Exec(
+		"UPDATE promo_codes SET used_count = used_count + 1 WHERE id = ? AND (usage_limit = 0 OR used_count < usage_limit)",
+		p.id,
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errPromoExhausted
+	}
+	return nil
+}
+
+type cartTotal struct {
+	Subtotal float64 `json:"subtotal"`
+	Discount float64 `json:"discount"`
+	Total    float64 `json:"total"`
+	Promo    string  `json:"promo,omitempty"`
+}
+
+// GetCartTotalHandler previews the cart total, optionally with a promo code
+// applied, without consuming the code — that happens at checkout.
+func GetCartTotalHandler(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r)
+
+	var subtotal float64
+	if err := database.DB.QueryRow(`
+		SELECT COALESCE(SUM(rec.price * ci.quantity), 0)
+		FROM cart_items ci
+		JOIN records rec ON rec.id = ci.record_id
+		WHERE ci.user_id = ?`, userID,
+	).Scan(&subtotal); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	result := cartTotal{Subtotal: subtotal, Total: subtotal}
+	if code := r.URL.Query().Get("promo"); code != "" {
+		p, err := lookupPromo(database.DB, code)
+		switch err {
+		case nil:
+		case errPromoNotFound, errPromoInactive, errPromoExhausted:
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		default:
+			respondWithError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		result.Promo = p.code
+		result.Discount = promoDiscount(p, subtotal)
+		result.Total = subtotal - result.Discount
+	}
+
+	respondWithJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// maxConcurrentPerUser caps in-flight requests per client so one user cannot
+// monopolize the single SQLite writer. Override with MAX_CONCURRENT_PER_USER.
+var maxConcurrentPerUser = concurrentLimitFromEnv()
+
+func concurrentLimitFromEnv() int {
+	if v, err := strconv.Atoi(os.Getenv("MAX_CONCURRENT_PER_USER")); err == nil && v > 0 {
+		return v
+	}
+	return 4
+}
+
+var (
+	inflightMu sync.Mutex
+	inflight   = map[string]int{}
+)
+
+// limiterKey identifies the caller: the JWT user id when a valid token is
+// present, otherwise the remote IP.
+func limiterKey(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	if header != "" {
+		claims := &Claims{}
+		token, err := jwt.ParseWithClaims(strings.TrimPrefix(header, "Bearer "), claims, func(t *jwt.Token) (interface{}, error) {
+			return jwtSecret, nil
+		})
+		if err == nil && token.Valid {
+			return "user:" + strconv.Itoa(claims.UserID)
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// requestRatePerMinute caps how many requests one caller may make per
+// minute across all /api routes, protecting the single SQLite writer from
+// any one abusive client. Override with REQUEST_RATE_PER_MINUTE; 0 disables
+// the limiter.
+var requestRatePerMinute = requestRateFromEnv()
+
+func requestRateFromEnv() int {
+	if v, err := strconv.Atoi(os.Getenv("REQUEST_RATE_PER_MINUTE")); err == nil && v >= 0 {
+		return v
+	}
+	return 300
+}
+
+// rateBucket is one caller's token bucket: it refills continuously at the
+// per-minute rate up to a burst of one minute's worth of requests.
+type rateBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+var (
+	bucketsMu sync.Mutex
+	buckets   = map[string]*rateBucket{}
+)
+
+// RateLimitMiddleware enforces requestRatePerMinute per caller — JWT user id
+// when authenticated, remote IP otherwise — and answers 429 with a
+// Retry-After header once the caller's bucket is empty. Other callers keep
+// their own buckets and are unaffected.
+func RateLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requestRatePerMinute <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		key := limiterKey(r)
+		limit := float64(requestRatePerMinute)
+		now := time.Now()
+
+		bucketsMu.Lock()
+		b := buckets[key]
+		if b == nil {
+			// Keep the map from growing without bound: full buckets carry no
+			// state worth remembering, so drop them when new keys arrive.
+			if len(buckets) > 10000 {
+				for k, old := range buckets {
+					if old.tokens >= limit {
+						delete(buckets, k)
+					}
+				}
+			}
+			b = &rateBucket{tokens: limit, last: now}
+			buckets[key] = b
+		}
+		b.tokens = math.Min(limit, b.tokens+now.Sub(b.last).Seconds()*limit/60)
+		b.last = now
+		if b.tokens < 1 {
+			retry := int(math.Ceil((1 - b.tokens) * 60 / limit))
+			bucketsMu.Unlock()
+			w.Header().Set("Retry-After", strconv.Itoa(retry))
+			respondWithError(w, http.StatusTooManyRequests, "request rate limit exceeded")
+			return
+		}
+		b.tokens--
+		bucketsMu.Unlock()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ConcurrencyLimitMiddleware returns 429 once a caller already has
+// maxConcurrentPerUser requests in flight. Other callers are unaffected.
+func ConcurrencyLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := limiterKey(r)
+
+		inflightMu.Lock()
+		if inflight[key] >= maxConcurrentPerUser {
+			inflightMu.Unlock()
+			respondWithError(w, http.StatusTooManyRequests, "too many concurrent requests")
+			return
+		}
+		inflight[key]++
+		inflightMu.Unlock()
+
+		defer func() {
+			inflightMu.Lock()
+			inflight[key]--
+			if inflight[key] == 0 {
+				delete(inflight, key)
+			}
+			inflightMu.Unlock()
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
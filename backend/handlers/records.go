@@ -0,0 +1,1216 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend/database"
+	"backend/models"
+
+	"github.com/gorilla/mux"
+)
+
+var errUnknownTrack = errors.New("one or more tracks do not exist")
+
+type recordPayload struct {
+	Name            string  `json:"name"`
+	Price           float64 `json:"price"`
+	Stock           int     `json:"stock"`
+	ReleaseDate     string  `json:"release_date"`
+	Label           string  `json:"label"`
+	Genre           string  `json:"genre"`
+	SoldLastYear    int     `json:"sold_last_year"`
+	SoldCurrentYear int     `json:"sold_current_year"`
+	WeightGrams     int     `json:"weight_grams"`
+	MaxPerOrder     int     `json:"max_per_order"`
+	TrackIDs        []int   `json:"track_ids"`
+
+	// datePrecision is filled in by validation, not by the client.
+	datePrecision string
+}
+
+// allowYearOnlyDates controls whether a bare YYYY release date is accepted.
+// Set RELEASE_DATE_STRICT=1 to require full YYYY-MM-DD dates.
+var allowYearOnlyDates = os.Getenv("RELEASE_DATE_STRICT") != "1"
+
+// normalizeReleaseDate validates a release date and reports its precision.
+// Full YYYY-MM-DD dates pass through with precision "day"; when year-only
+// input is allowed, a bare YYYY is normalized to YYYY-01-01 with precision
+// "year" so the UI can display it accordingly.
+func normalizeReleaseDate(date string) (normalized, precision string, ok bool) {
+	if date == "" {
+		return "", "day", true
+	}
+	if _, err := time.Parse("2006-01-02", date); err == nil {
+		return date, "day", true
+	}
+	if allowYearOnlyDates {
+		if _, err := time.Parse("2006", date); err == nil {
+			return date + "-01-01", "year", true
+		}
+	}
+	return "", "", false
+}
+
+// recordSortColumns whitelists what ?sort= may order by; the value is the
+// SQL column, so nothing from the query string is ever interpolated into
+// the statement.
+var recordSortColumns = map[string]string{
+	"id":           "id",
+	"name":         "name",
+	"price":        "price",
+	"stock":        "stock",
+	"release_date": "release_date",
+	"label":        "label",
+}
+
+// parseRecordSort reads ?sort= (column with an optional ",desc") for the
+// catalog listing, defaulting to id ascending.
+func parseRecordSort(r *http.Request) (orderBy string, err error) {
+	column, direction := "id", "ASC"
+	if raw := r.URL.Query().Get("sort"); raw != "" {
+		parts := strings.SplitN(raw, ",", 2)
+		col, ok := recordSortColumns[parts[0]]
+		if !ok {
+			return "", errors.New("invalid sort column: " + parts[0])
+		}
+		column = col
+		if len(parts) == 2 {
+			switch parts[1] {
+			case "asc":
+				direction = "ASC"
+			case "desc":
+				direction = "DESC"
+			default:
+				return "", errors.New("sort direction must be asc or desc")
+			}
+		}
+	}
+	// A secondary id sort keeps the order stable when the column has ties.
+	return column + " " + direction + ", id", nil
+}
+
+// parsePriceRange reads the optional ?minPrice= and ?maxPrice= bounds for
+// the catalog listing. Both must be non-negative numbers and min must not
+// exceed max; nil means the bound was not given.
+func parsePriceRange(r *http.Request) (minPrice, maxPrice *float64, err error) {
+	parse := func(name string) (*float64, error) {
+		raw := r.URL.Query().Get(name)
+		if raw == "" {
+			return nil, nil
+		}
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil || v < 0 {
+			return nil, errors.New(name + " must be a non-negative number")
+		}
+		return &v, nil
+	}
+	if minPrice, err = parse("minPrice"); err != nil {
+		return nil, nil, err
+	}
+	if maxPrice, err = parse("maxPrice"); err != nil {
+		return nil, nil, err
+	}
+	if minPrice != nil && maxPrice != nil && *minPrice > *maxPrice {
+		return nil, nil, errors.New("minPrice must not exceed maxPrice")
+	}
+	return minPrice, maxPrice, nil
+}
+
+// GetRecordsHandler returns a page of the catalog with tracks attached, in
+// the shared pagination envelope every other listing uses. Rendered listings
+// are cached per query string until the TTL passes or a catalog mutation
+// bumps the version counter.
+func GetRecordsHandler(w http.ResponseWriter, r *http.Request) {
+	// Archived records are soft-deleted merge-aways, not public catalog, so
+	// only admins may ask for them. The check sits before the cache lookup —
+	// otherwise an admin's includeArchived listing would be cached under the
+	// same query-string key and replayed to anonymous callers.
+	if r.URL.Query().Get("includeArchived") == "true" && requestRole(r) != "admin" {
+		respondWithError(w, http.StatusForbidden, "admin access required")
+		return
+	}
+
+	cacheKey := r.URL.RawQuery
+	if body, ok := cachedRecordsResponse(cacheKey); ok {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(body)
+		return
+	}
+
+	fields, err := parseRecordFields(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	orderBy, err := parseRecordSort(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	page, pageSize := parsePagination(r)
+
+	minPrice, maxPrice, err := parsePriceRange(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	conditions := []string{}
+	args := []interface{}{}
+	if r.URL.Query().Get("includeArchived") != "true" {
+		conditions = append(conditions, "archived = 0")
+	}
+	if genre := r.URL.Query().Get("genre"); genre != "" {
+		conditions = append(conditions, "genre = ?")
+		args = append(args, genre)
+	}
+	if minPrice != nil {
+		conditions = append(conditions, "price >= ?")
+		args = append(args, *minPrice)
+	}
+	if maxPrice != nil {
+		conditions = append(conditions, "price <= ?")
+		args = append(args, *maxPrice)
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := database.DB.QueryRow("SELECT COUNT(*) FROM records "+where, args...).Scan(&total); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	rows, err := database.DB.Query(
+		"SELECT id, name, price, stock, release_date, date_precision, label, genre, sold_last_year, sold_current_year, weight_grams, max_per_order, archived FROM records "+
+			where+" ORDER BY "+orderBy+" LIMIT ? OFFSET ?", append(args, pageSize, (page-1)*pageSize)...)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	defer rows.Close()
+
+	records := []models.Record{}
+	for rows.Next() {
+		var rec models.Record
+		if err := rows.Scan(&rec.ID, &rec.Name, &rec.Price, &rec.Stock, &rec.ReleaseDate, &rec.DatePrecision, &rec.Label, &rec.Genre, &rec.SoldLastYear, &rec.SoldCurrentYear, &rec.WeightGrams, &rec.MaxPerOrder, &rec.Archived); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		records = append(records, rec)
+	}
+
+	// A fields projection that does not ask for tracks skips the track
+	// hydration entirely — the main point of the parameter for list views
+	// that only need a couple of columns.
+	if fieldsWantTracks(fields) {
+		if err := hydrateRecordTracks(records); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+	}
+
+	var items interface{} = records
+	if fields != nil {
+		projected := make([]map[string]interface{}, len(records))
+		for i, rec := range records {
+			projected[i] = projectRecord(rec, fields)
+		}
+		items = projected
+	}
+
+	body, err := json.Marshal(models.NewPage(items, total, page, pageSize))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+	storeRecordsResponse(cacheKey, body)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+// hydrateRecordTracks attaches each record's tracks in place. All records
+// are served by one IN-clause query (chunked against the bound-parameter
+// limit) instead of a query per record; the IN list's placeholders are
+// concatenated into the SQL, never the values. Every handler that returns
+// records with tracks must go through here rather than copying the query.
+func hydrateRecordTracks(records []models.Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+	byID := make(map[int]*models.Record, len(records))
+	ids := make([]int, len(records))
+	for i := range records {
+		ids[i] = records[i].ID
+		byID[records[i].ID] = &records[i]
+	}
+	return forEachIDChunk(ids, func(placeholders string, args []interface{}) error {
+		trackRows, err := database.DB.Query(`
+			SELECT rt.record_id, t.id, t.name, t.duration, t.musician_id, t.ensemble_id
+			FROM tracks t
+			JOIN record_tracks rt ON rt.track_id = t.id
+			WHERE rt.record_id IN (`+placeholders+`)
+			ORDER BY rt.record_id, t.id`, args...)
+		if err != nil {
+			return err
+		}
+		defer trackRows.Close()
+		for trackRows.Next() {
+			var recordID int
+			var t models.Track
+			if err := trackRows.Scan(&recordID, &t.ID, &t.Name, &t.Duration, &t.MusicianID, &t.EnsembleID); err != nil {
+				return err
+			}
+			byID[recordID].Tracks = append(byID[recordID].Tracks, t)
+		}
+		return trackRows.Err()
+	})
+}
+
+// recordFieldIndex maps the JSON field names of models.Record to their
+// struct field indices, so ?fields= is validated against the struct itself
+// rather than a hand-maintained list.
+var recordFieldIndex = buildRecordFieldIndex()
+
+func buildRecordFieldIndex() map[string]int {
+	t := reflect.TypeOf(models.Record{})
+	index := make(map[string]int, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		tag := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if tag != "" && tag != "-" {
+			index[tag] = i
+		}
+	}
+	return index
+}
+
+// parseRecordFields reads the ?fields= parameter: nil means no projection,
+// an unknown field name is an error naming the offender.
+func parseRecordFields(r *http.Request) ([]string, error) {
+	raw := r.URL.Query().Get("fields")
+	if raw == "" {
+		return nil, nil
+	}
+	fields := strings.Split(raw, ",")
+	for _, f := range fields {
+		if _, ok := recordFieldIndex[f]; !ok {
+			return nil, errors.New("unknown field: " + f)
+		}
+	}
+	return fields, nil
+}
+
+// fieldsWantTracks reports whether the projection needs track hydration; the
+// unprojected response always includes tracks.
+func fieldsWantTracks(fields []string) bool {
+	if fields == nil {
+		return true
+	}
+	for _, f := range fields {
+		if f == "tracks" {
+			return true
+		}
+	}
+	return false
+}
+
+// projectRecord reduces a record to just the requested JSON fields.
+func projectRecord(rec models.Record, fields []string) map[string]interface{} {
+	v := reflect.ValueOf(rec)
+	out := make(map[string]interface{}, len(fields))
+	for _, f := range fields {
+		out[f] = v.Field(recordFieldIndex[f]).Interface()
+	}
+	return out
+}
+
+// GetRecordHandler returns a single record with its tracks.
+func GetRecordHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid record id")
+		return
+	}
+
+	var rec models.Record
+	err = database.DB.QueryRow(
+		"SELECT id, name, price, stock, release_date, date_precision, label, genre, sold_last_year, sold_current_year, weight_grams, max_per_order, archived FROM records WHERE id = ?", id,
+	).Scan(&rec.ID, &rec.Name, &rec.Price, &rec.Stock, &rec.ReleaseDate, &rec.DatePrecision, &rec.Label, &rec.Genre, &rec.SoldLastYear, &rec.SoldCurrentYear, &rec.WeightGrams, &rec.MaxPerOrder, &rec.Archived)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "record not found")
+		return
+	}
+
+	recs := []models.Record{rec}
+	if err := hydrateRecordTracks(recs); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, recs[0])
+}
+
+type fullTrackMusician struct {
+	ID        int      `json:"id"`
+	Name      string   `json:"name"`
+	Ensembles []string `json:"ensembles"`
+}
+
+type fullTrackEnsemble struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	Archived bool   `json:"archived"`
+}
+
+type fullTrack struct {
+	ID       int                `json:"id"`
+	Name     string             `json:"name"`
+	Duration int                `json:"duration"`
+	Musician *fullTrackMusician `json:"musician,omitempty"`
+	Ensemble *fullTrackEnsemble `json:"ensemble,omitempty"`
+}
+
+type recordTotals struct {
+	TrackCount    int     `json:"track_count"`
+	TotalDuration int     `json:"total_duration"`
+	StockValue    float64 `json:"stock_value"`
+	UnitsSold     int     `json:"units_sold"`
+}
+
+type recordReference struct {
+	UserID   int `json:"user_id"`
+	OrderID  int `json:"order_id,omitempty"`
+	Quantity int `json:"quantity"`
+}
+
+type fullRecord struct {
+	Record models.Record     `json:"record"`
+	Tracks []fullTrack       `json:"tracks"`
+	Totals recordTotals      `json:"totals"`
+	Carts  []recordReference `json:"carts"`
+	Orders []recordReference `json:"orders"`
+}
+
+// GetFullRecordHandler returns everything the admin detail view needs about
+// one record in a single response: the record itself, its tracks with their
+// resolved owners (a musician with the ensembles they play in, or an
+// ensemble), computed totals, and the carts and orders that reference it.
+func GetFullRecordHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid record id")
+		return
+	}
+
+	full := fullRecord{Tracks: []fullTrack{}, Carts: []recordReference{}, Orders: []recordReference{}}
+	err = database.DB.QueryRow(
+		"SELECT id, name, price, stock, release_date, date_precision, label, genre, sold_last_year, sold_current_year, weight_grams, max_per_order, archived FROM records WHERE id = ?", id,
+	).Scan(&full.Record.ID, &full.Record.Name, &full.Record.Price, &full.Record.Stock, &full.Record.ReleaseDate, &full.Record.DatePrecision, &full.Record.Label, &full.Record.Genre, &full.Record.SoldLastYear, &full.Record.SoldCurrentYear, &full.Record.WeightGrams, &full.Record.MaxPerOrder, &full.Record.Archived)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "record not found")
+		return
+	}
+
+	trackRows, err := database.DB.Query(`
+		SELECT t.id, t.name, t.duration, t.musician_id, m.name, t.ensemble_id, e.name, e.archived
+		FROM tracks t
+		JOIN record_tracks rt ON rt.track_id = t.id
+		LEFT JOIN musicians m ON m.id = t.musician_id
+		LEFT JOIN ensembles e ON e.id = t.ensemble_id
+		WHERE rt.record_id = ?
+		ORDER BY t.id`, id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	defer trackRows.Close()
+	for trackRows.Next() {
+		var t fullTrack
+		var musicianID, ensembleID *int
+		var musicianName, ensembleName *string
+		var ensembleArchived *bool
+		if err := trackRows.Scan(&t.ID, &t.Name, &t.Duration, &musicianID, &musicianName, &ensembleID, &ensembleName, &ensembleArchived); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		if musicianID != nil && musicianName != nil {
+			t.Musician = &fullTrackMusician{ID: *musicianID, Name: *musicianName, Ensembles: []string{}}
+		}
+		if ensembleID != nil && ensembleName != nil && ensembleArchived != nil {
+			t.Ensemble = &fullTrackEnsemble{ID: *ensembleID, Name: *ensembleName, Archived: *ensembleArchived}
+		}
+		full.Tracks = append(full.Tracks, t)
+		full.Totals.TrackCount++
+		full.Totals.TotalDuration += t.Duration
+	}
+
+	// Resolve each track musician's ensembles through the roster table.
+	for _, t := range full.Tracks {
+		if t.Musician == nil {
+			continue
+		}
+		names, err := database.DB.Query(`
+			SELECT e.name FROM ensembles e
+			JOIN ensemble_musicians em ON em.ensemble_id = e.id
+			WHERE em.musician_id = ?
+			ORDER BY e.id`, t.Musician.ID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		for names.Next() {
+			var name string
+			if err := names.Scan(&name); err != nil {
+				names.Close()
+				respondWithError(w, http.StatusInternalServerError, "database error")
+				return
+			}
+			t.Musician.Ensembles = append(t.Musician.Ensembles, name)
+		}
+		names.Close()
+	}
+
+	full.Totals.StockValue = float64(full.Record.Stock) * full.Record.Price
+	if err := database.DB.QueryRow(
+		"SELECT COALESCE(SUM(quantity), 0) FROM order_items WHERE record_id = ?", id,
+	).Scan(&full.Totals.UnitsSold); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	cartRows, err := database.DB.Query(
+		"SELECT user_id, quantity FROM cart_items WHERE record_id = ? ORDER BY user_id", id,
+	)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	defer cartRows.Close()
+	for cartRows.Next() {
+		var ref recordReference
+		if err := cartRows.Scan(&ref.UserID, &ref.Quantity); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		full.Carts = append(full.Carts, ref)
+	}
+
+	orderRows, err := database.DB.Query(`
+		SELECT o.id, o.user_id, oi.quantity
+		FROM order_items oi
+		JOIN orders o ON o.id = oi.order_id
+		WHERE oi.record_id = ?
+		ORDER BY o.id`, id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	defer orderRows.Close()
+	for orderRows.Next() {
+		var ref recordReference
+		if err := orderRows.Scan(&ref.OrderID, &ref.UserID, &ref.Quantity); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		full.Orders = append(full.Orders, ref)
+	}
+
+	respondWithJSON(w, http.StatusOK, full)
+}
+
+// CreateRecordHandler adds a record to the catalog.
+func CreateRecordHandler(w http.ResponseWriter, r *http.Request) {
+	var payload recordPayload
+	if err := decodeJSONBody(r, &payload); err != nil {
+		if err == errEmptyBody {
+			respondWithError(w, http.StatusBadRequest, "request body is required")
+			return
+		}
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if payload.Name == "" {
+		respondWithError(w, http.StatusBadRequest, "name is required")
+		return
+	}
+	if err := validateName("name", payload.Name); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if payload.Price < 0 {
+		respondWithError(w, http.StatusBadRequest, "price cannot be negative")
+		return
+	}
+	releaseDate, precision, ok := normalizeReleaseDate(payload.ReleaseDate)
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "release_date must be in YYYY-MM-DD or YYYY format")
+		return
+	}
+
+	id, err := database.DB.Insert(
+		"INSERT INTO records (name, price, stock, release_date, date_precision, label, genre, sold_last_year, sold_current_year, weight_grams, max_per_order) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		sanitizeText(payload.Name), payload.Price, payload.Stock, releaseDate, precision, sanitizeText(payload.Label), sanitizeText(payload.Genre), payload.SoldLastYear, payload.SoldCurrentYear, payload.WeightGrams, payload.MaxPerOrder,
+	)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	// The opening price starts the history, so the first real change has a
+	// baseline to diff against instead of appearing out of nowhere.
+	if _, err := database.DB.Exec(
+		"INSERT INTO price_history (record_id, price) VALUES (?, ?)", int(id), payload.Price,
+	); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	var skipped []trackLinkSkip
+	if len(payload.TrackIDs) > 0 {
+		skipped, err = linkTracks(database.DB, int(id), payload.TrackIDs)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	bumpCatalogVersion()
+	recordAudit(r, "create", "record", int(id))
+
+	response := map[string]interface{}{"id": int(id)}
+	if len(skipped) > 0 {
+		response["skippedTracks"] = skipped
+	}
+	respondWithJSON(w, http.StatusCreated, response)
+}
+
+type trackLinkSkip struct {
+	TrackID int    `json:"trackId"`
+	Error   string `json:"error"`
+}
+
+// queryExecer lets linkTracks run against either the shared DB handle or an
+// open transaction.
+type queryExecer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *database.Row
+}
+
+// linkTracks attaches the given tracks to a record, verifying they exist.
+// Tracks with neither a musician nor an ensemble — bad data that would show
+// up on the record with no artist — are not linked; they come back as skips
+// with a reason so the caller can surface them.
+func linkTracks(db queryExecer, recordID int, trackIDs []int) ([]trackLinkSkip, error) {
+	// The existence check is chunked so huge track lists do not blow
+	// SQLite's bound-parameter limit.
+	var count int
+	err := forEachIDChunk(trackIDs, func(placeholders string, args []interface{}) error {
+		var n int
+		if err := db.QueryRow(
+			"SELECT COUNT(*) FROM tracks WHERE id IN ("+placeholders+")", args...,
+		).Scan(&n); err != nil {
+			return err
+		}
+		count += n
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if count != len(trackIDs) {
+		return nil, errUnknownTrack
+	}
+
+	skipped := []trackLinkSkip{}
+	for _, trackID := range trackIDs {
+		var owned int
+		if err := db.QueryRow(
+			"SELECT COUNT(*) FROM tracks WHERE id = ? AND (musician_id IS NOT NULL OR ensemble_id IS NOT NULL)", trackID,
+		).Scan(&owned); err != nil {
+			return nil, err
+		}
+		if owned == 0 {
+			skipped = append(skipped, trackLinkSkip{TrackID: trackID, Error: "track has no owner"})
+			continue
+		}
+		if _, err := db.Exec(
+			"INSERT INTO record_tracks (record_id, track_id) VALUES (?, ?) ON CONFLICT DO NOTHING", recordID, trackID,
+		); err != nil {
+			return nil, err
+		}
+	}
+	return skipped, nil
+}
+
+// UpdateRecordHandler updates a record's catalog fields and, when track_ids
+// is present in the payload, replaces its tracklist.
+func UpdateRecordHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid record id")
+		return
+	}
+
+	var payload recordPayload
+	if err := decodeJSONBody(r, &payload); err != nil {
+		if err == errEmptyBody {
+			respondWithError(w, http.StatusBadRequest, "request body is required")
+			return
+		}
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if payload.Name == "" {
+		respondWithError(w, http.StatusBadRequest, "name is required")
+		return
+	}
+	if err := validateName("name", payload.Name); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if payload.Price < 0 {
+		respondWithError(w, http.StatusBadRequest, "price cannot be negative")
+		return
+	}
+	releaseDate, precision, ok := normalizeReleaseDate(payload.ReleaseDate)
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "release_date must be in YYYY-MM-DD or YYYY format")
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	defer tx.Rollback()
+
+	var oldPrice float64
+	err = tx.QueryRow("SELECT price FROM records WHERE id = ?", id).Scan(&oldPrice)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "record not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	if _, err := tx.Exec(
+		"UPDATE records SET name = ?, price = ?, stock = ?, release_date = ?, date_precision = ?, label = ?, genre = ?, sold_last_year = ?, sold_current_year = ?, weight_grams = ?, max_per_order = ? WHERE id = ?",
+		sanitizeText(payload.Name), payload.Price, payload.Stock, releaseDate, precision, sanitizeText(payload.Label), sanitizeText(payload.Genre), payload.SoldLastYear, payload.SoldCurrentYear, payload.WeightGrams, payload.MaxPerOrder, id,
+	); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	// Record the price change alongside the update; no-op updates leave the
+	// history untouched.
+	if payload.Price != oldPrice {
+		if _, err := tx.Exec(
+			"INSERT INTO price_history (record_id, price) VALUES (?, ?)", id, payload.Price,
+		); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+	}
+
+	// A present track_ids replaces the tracklist wholesale — the existing
+	// links are dropped and the new list goes through linkTracks with the
+	// same validation and skips as on create, all inside the transaction, so
+	// a bad id rolls back both the field update and the delete and the old
+	// tracklist survives intact. An absent track_ids (nil slice) leaves the
+	// tracklist alone, so clients can update prices without resending every
+	// track; an explicit empty list clears it.
+	var skipped []trackLinkSkip
+	if payload.TrackIDs != nil {
+		if _, err := tx.Exec("DELETE FROM record_tracks WHERE record_id = ?", id); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		if len(payload.TrackIDs) > 0 {
+			skipped, err = linkTracks(tx, id, payload.TrackIDs)
+			if err != nil {
+				respondWithError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	bumpCatalogVersion()
+	recordAudit(r, "update", "record", id)
+
+	response := map[string]interface{}{"status": "updated"}
+	if len(skipped) > 0 {
+		response["skippedTracks"] = skipped
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// RecomputeSalesHandler rebuilds sold_current_year and sold_last_year for
+// every record from actual order history, treating the year query parameter
+// (default: this year) as "current". Runs in one transaction so the counters
+// never mix old and new values.
+func RecomputeSalesHandler(w http.ResponseWriter, r *http.Request) {
+	year := time.Now().Year()
+	if v := r.URL.Query().Get("year"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 1900 {
+			respondWithError(w, http.StatusBadRequest, "invalid year")
+			return
+		}
+		year = parsed
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	defer tx.Rollback()
+
+	soldInYear := `COALESCE((
+		SELECT SUM(oi.quantity) FROM order_items oi
+		JOIN orders o ON o.id = oi.order_id
+		WHERE oi.record_id = records.id
+		  AND o.created_at >= ? AND o.created_at < ?
+	), 0)`
+	if _, err := tx.Exec(
+		"UPDATE records SET sold_current_year = "+soldInYear+", sold_last_year = "+soldInYear,
+		yearStart(year), yearStart(year+1), yearStart(year-1), yearStart(year),
+	); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	bumpCatalogVersion()
+	recordAudit(r, "update", "record", 0)
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{"status": "recomputed", "year": year})
+}
+
+func yearStart(year int) string {
+	return strconv.Itoa(year) + "-01-01"
+}
+
+type priceHistoryEntry struct {
+	Price     float64 `json:"price"`
+	ChangedAt string  `json:"changed_at"`
+}
+
+// GetRecordPriceHistoryHandler returns a record's price changes in
+// chronological order.
+func GetRecordPriceHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid record id")
+		return
+	}
+
+	var exists int
+	if err := database.DB.QueryRow("SELECT COUNT(*) FROM records WHERE id = ?", id).Scan(&exists); err != nil || exists == 0 {
+		respondWithError(w, http.StatusNotFound, "record not found")
+		return
+	}
+
+	rows, err := database.DB.Query(
+		"SELECT price, changed_at FROM price_history WHERE record_id = ? ORDER BY id", id,
+	)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	defer rows.Close()
+
+	history := []priceHistoryEntry{}
+	for rows.Next() {
+		var e priceHistoryEntry
+		if err := rows.Scan(&e.Price, &e.ChangedAt); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		history = append(history, e)
+	}
+
+	respondWithJSON(w, http.StatusOK, history)
+}
+
+type suggestedTrack struct {
+	TrackID       int    `json:"trackId"`
+	Name          string `json:"name"`
+	CoOccurrences int    `json:"coOccurrences"`
+}
+
+// GetSuggestedTracksHandler ranks "complete the set" candidates for a record:
+// tracks that appear on other records alongside this record's tracks but are
+// not on this record yet, ordered by how many of those records they share.
+// It is a lightweight recommendation over the existing record_tracks data.
+func GetSuggestedTracksHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid record id")
+		return
+	}
+
+	var exists int
+	if err := database.DB.QueryRow("SELECT COUNT(*) FROM records WHERE id = ?", id).Scan(&exists); err != nil || exists == 0 {
+		respondWithError(w, http.StatusNotFound, "record not found")
+		return
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT rt.track_id, t.name, COUNT(DISTINCT rt.record_id) AS co
+		FROM record_tracks rt
+		JOIN tracks t ON t.id = rt.track_id
+		WHERE rt.record_id IN (
+			SELECT DISTINCT record_id FROM record_tracks
+			WHERE record_id != ?
+			  AND track_id IN (SELECT track_id FROM record_tracks WHERE record_id = ?))
+		  AND rt.track_id NOT IN (SELECT track_id FROM record_tracks WHERE record_id = ?)
+		GROUP BY rt.track_id, t.name
+		ORDER BY co DESC, rt.track_id`, id, id, id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	defer rows.Close()
+
+	suggestions := []suggestedTrack{}
+	for rows.Next() {
+		var s suggestedTrack
+		if err := rows.Scan(&s.TrackID, &s.Name, &s.CoOccurrences); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		suggestions = append(suggestions, s)
+	}
+
+	respondWithJSON(w, http.StatusOK, suggestions)
+}
+
+type recordOrder struct {
+	OrderID   int     `json:"order_id"`
+	UserID    int     `json:"user_id"`
+	Email     string  `json:"email"`
+	Quantity  int     `json:"quantity"`
+	Price     float64 `json:"price"`
+	CreatedAt string  `json:"created_at"`
+}
+
+// GetRecordOrdersHandler lists the orders that included a record, newest
+// first, with quantity and buyer, in the shared pagination envelope. This is
+// the per-record sales detail behind the aggregate reports.
+func GetRecordOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid record id")
+		return
+	}
+
+	var exists int
+	if err := database.DB.QueryRow("SELECT COUNT(*) FROM records WHERE id = ?", id).Scan(&exists); err != nil || exists == 0 {
+		respondWithError(w, http.StatusNotFound, "record not found")
+		return
+	}
+
+	var total int
+	if err := database.DB.QueryRow("SELECT COUNT(*) FROM order_items WHERE record_id = ?", id).Scan(&total); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	page, pageSize := parsePagination(r)
+	rows, err := database.DB.Query(`
+		SELECT o.id, o.user_id, u.email, oi.quantity, oi.price, o.created_at
+		FROM order_items oi
+		JOIN orders o ON o.id = oi.order_id
+		JOIN users u ON u.id = o.user_id
+		WHERE oi.record_id = ?
+		ORDER BY o.id DESC
+		LIMIT ? OFFSET ?`, id, pageSize, (page-1)*pageSize)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	defer rows.Close()
+
+	orders := []recordOrder{}
+	for rows.Next() {
+		var o recordOrder
+		if err := rows.Scan(&o.OrderID, &o.UserID, &o.Email, &o.Quantity, &o.Price, &o.CreatedAt); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		orders = append(orders, o)
+	}
+
+	respondWithJSON(w, http.StatusOK, models.NewPage(orders, total, page, pageSize))
+}
+
+type tracklistNameMatch struct {
+	Name string `json:"name"`
+	AID  int    `json:"aTrackId"`
+	BID  int    `json:"bTrackId"`
+}
+
+type tracklistDiff struct {
+	OnlyA       []models.Track       `json:"onlyA"`
+	OnlyB       []models.Track       `json:"onlyB"`
+	Both        []models.Track       `json:"both"`
+	NameMatches []tracklistNameMatch `json:"nameMatches"`
+}
+
+func recordTracklist(recordID int) ([]models.Track, error) {
+	rows, err := database.DB.Query(`
+		SELECT t.id, t.name, t.duration, t.musician_id, t.ensemble_id
+		FROM tracks t
+		JOIN record_tracks rt ON rt.track_id = t.id
+		WHERE rt.record_id = ?
+		ORDER BY t.id`, recordID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tracks := []models.Track{}
+	for rows.Next() {
+		var t models.Track
+		if err := rows.Scan(&t.ID, &t.Name, &t.Duration, &t.MusicianID, &t.EnsembleID); err != nil {
+			return nil, err
+		}
+		tracks = append(tracks, t)
+	}
+	return tracks, rows.Err()
+}
+
+// CompareRecordsHandler diffs the tracklists of two records for the
+// merge-duplicates workflow: tracks only on A, only on B, shared by id, and
+// pairs that are distinct rows but carry the same name — near-duplicates
+// worth a closer look before merging.
+func CompareRecordsHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	aID, errA := strconv.Atoi(q.Get("a"))
+	bID, errB := strconv.Atoi(q.Get("b"))
+	if errA != nil || errB != nil {
+		respondWithError(w, http.StatusBadRequest, "a and b record ids are required")
+		return
+	}
+
+	for _, id := range []int{aID, bID} {
+		var exists int
+		if err := database.DB.QueryRow("SELECT COUNT(*) FROM records WHERE id = ?", id).Scan(&exists); err != nil || exists == 0 {
+			respondWithError(w, http.StatusNotFound, "record not found")
+			return
+		}
+	}
+
+	aTracks, err := recordTracklist(aID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	bTracks, err := recordTracklist(bID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	bByID := map[int]bool{}
+	for _, t := range bTracks {
+		bByID[t.ID] = true
+	}
+	aByID := map[int]bool{}
+	for _, t := range aTracks {
+		aByID[t.ID] = true
+	}
+
+	diff := tracklistDiff{
+		OnlyA:       []models.Track{},
+		OnlyB:       []models.Track{},
+		Both:        []models.Track{},
+		NameMatches: []tracklistNameMatch{},
+	}
+	for _, t := range aTracks {
+		if bByID[t.ID] {
+			diff.Both = append(diff.Both, t)
+		} else {
+			diff.OnlyA = append(diff.OnlyA, t)
+		}
+	}
+	for _, t := range bTracks {
+		if !aByID[t.ID] {
+			diff.OnlyB = append(diff.OnlyB, t)
+		}
+	}
+	for _, a := range diff.OnlyA {
+		for _, b := range diff.OnlyB {
+			if a.Name == b.Name {
+				diff.NameMatches = append(diff.NameMatches, tracklistNameMatch{Name: a.Name, AID: a.ID, BID: b.ID})
+			}
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, diff)
+}
+
+type mergeRecordsPayload struct {
+	SourceID int `json:"sourceId"`
+	TargetID int `json:"targetId"`
+}
+
+// MergeRecordsHandler folds a duplicate record into its canonical copy: the
+// source's track links are unioned into the target, cart lines and order
+// items move over (quantities summed where both appear), sold counters are
+// added up, and the source is soft-archived rather than deleted so history
+// pointing at it stays explicable. Everything runs in one transaction.
+func MergeRecordsHandler(w http.ResponseWriter, r *http.Request) {
+	var payload mergeRecordsPayload
+	if err := decodeJSONBody(r, &payload); err != nil {
+		if err == errEmptyBody {
+			respondWithError(w, http.StatusBadRequest, "request body is required")
+			return
+		}
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if payload.SourceID == payload.TargetID {
+		respondWithError(w, http.StatusBadRequest, "source and target must be different records")
+		return
+	}
+
+	for _, id := range []int{payload.SourceID, payload.TargetID} {
+		var exists int
+		if err := database.DB.QueryRow("SELECT COUNT(*) FROM records WHERE id = ?", id).Scan(&exists); err != nil || exists == 0 {
+			respondWithError(w, http.StatusNotFound, "record not found")
+			return
+		}
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		INSERT INTO record_tracks (record_id, track_id)
+		SELECT ?, track_id FROM record_tracks WHERE record_id = ?
+		ON CONFLICT DO NOTHING`,
+		payload.TargetID, payload.SourceID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	// Fold cart lines the same way user merges do: sum quantities where a
+	// cart holds both records, then drop the source lines.
+	if _, err := tx.Exec(`
+		INSERT INTO cart_items (user_id, record_id, quantity)
+		SELECT user_id, ?, quantity FROM cart_items WHERE record_id = ?
+		ON CONFLICT(user_id, record_id) DO UPDATE SET quantity = quantity + excluded.quantity`,
+		payload.TargetID, payload.SourceID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	if _, err := tx.Exec("DELETE FROM cart_items WHERE record_id = ?", payload.SourceID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	// Order items: where an order contains both records, add the source
+	// quantity onto the target line and delete the source line; the rest are
+	// simply repointed.
+	if _, err := tx.Exec(`
+		UPDATE order_items SET quantity = quantity + (
+			SELECT s.quantity FROM order_items s
+			WHERE s.order_id = order_items.order_id AND s.record_id = ?)
+		WHERE record_id = ? AND EXISTS (
+			SELECT 1 FROM order_items s
+			WHERE s.order_id = order_items.order_id AND s.record_id = ?)`,
+		payload.SourceID, payload.TargetID, payload.SourceID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	if _, err := tx.Exec(`
+		DELETE FROM order_items WHERE record_id = ? AND order_id IN (
+			SELECT order_id FROM order_items WHERE record_id = ?)`,
+		payload.SourceID, payload.TargetID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	if _, err := tx.Exec(
+		"UPDATE order_items SET record_id = ? WHERE record_id = ?",
+		payload.TargetID, payload.SourceID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE records SET
+			sold_last_year = sold_last_year + (SELECT sold_last_year FROM records WHERE id = ?),
+			sold_current_year = sold_current_year + (SELECT sold_current_year FROM records WHERE id = ?)
+		WHERE id = ?`,
+		payload.SourceID, payload.SourceID, payload.TargetID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	if _, err := tx.Exec("UPDATE records SET archived = 1 WHERE id = ?", payload.SourceID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	bumpCatalogVersion()
+	recordAudit(r, "merge", "record", payload.SourceID)
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "merged"})
+}
+
+// DeleteRecordHandler removes a record from the catalog.
+func DeleteRecordHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid record id")
+		return
+	}
+
+	res, err := database.DB.Exec("DELETE FROM records WHERE id = ?", id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		respondWithError(w, http.StatusNotFound, "record not found")
+		return
+	}
+
+	bumpCatalogVersion()
+	recordAudit(r, "delete", "record", id)
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
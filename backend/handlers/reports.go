@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend/database"
+	"backend/models"
+)
+
+type bestsellerRow struct {
+	RecordID        int     `json:"record_id"`
+	Name            string  `json:"name"`
+	Sold            int     `json:"sold"`
+	Label           string  `json:"label"`
+	Price           float64 `json:"price"`
+	SoldCurrentYear int     `json:"sold_current_year"`
+	SoldLastYear    int     `json:"sold_last_year"`
+}
+
+type labelSummary struct {
+	Label        string  `json:"label"`
+	RecordCount  int     `json:"record_count"`
+	TotalStock   int     `json:"total_stock"`
+	AveragePrice float64 `json:"average_price"`
+	TotalSold    int     `json:"total_sold"`
+}
+
+// GetLabelSummariesHandler returns one aggregate row per label: record
+// count, total stock, average price and total units sold last year. Records
+// without a label are grouped under "(unlabeled)".
+func GetLabelSummariesHandler(w http.ResponseWriter, r *http.Request) {
+	rows, err := database.DB.Query(`
+		SELECT CASE WHEN label IS NULL OR label = '' THEN '(unlabeled)' ELSE label END AS lbl,
+		       COUNT(*), SUM(stock), AVG(price), SUM(sold_last_year)
+		FROM records
+		GROUP BY lbl
+		ORDER BY COUNT(*) DESC, lbl`)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	defer rows.Close()
+
+	summaries := []labelSummary{}
+	for rows.Next() {
+		var s labelSummary
+		if err := rows.Scan(&s.Label, &s.RecordCount, &s.TotalStock, &s.AveragePrice, &s.TotalSold); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		summaries = append(summaries, s)
+	}
+
+	respondWithJSON(w, http.StatusOK, summaries)
+}
+
+// GetRecordsByArtistHandler lists the records that feature a given musician,
+// a given ensemble, or both. Since a track is owned by exactly one of the
+// two, "both" means the record carries at least one track by each.
+func GetRecordsByArtistHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	where := "WHERE 1=1"
+	args := []interface{}{}
+	if v := q.Get("musicianId"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "invalid musicianId filter")
+			return
+		}
+		where += ` AND EXISTS (
+			SELECT 1 FROM record_tracks rt JOIN tracks t ON t.id = rt.track_id
+			WHERE rt.record_id = rec.id AND t.musician_id = ?)`
+		args = append(args, id)
+	}
+	if v := q.Get("ensembleId"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "invalid ensembleId filter")
+			return
+		}
+		where += ` AND EXISTS (
+			SELECT 1 FROM record_tracks rt JOIN tracks t ON t.id = rt.track_id
+			WHERE rt.record_id = rec.id AND t.ensemble_id = ?)`
+		args = append(args, id)
+	}
+	if len(args) == 0 {
+		respondWithError(w, http.StatusBadRequest, "musicianId or ensembleId is required")
+		return
+	}
+
+	rows, err := database.DB.Query(
+		"SELECT rec.id, rec.name, rec.release_date, rec.label FROM records rec "+where+" ORDER BY rec.id", args...,
+	)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	defer rows.Close()
+
+	records := []models.Record{}
+	for rows.Next() {
+		var rec models.Record
+		if err := rows.Scan(&rec.ID, &rec.Name, &rec.ReleaseDate, &rec.Label); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		records = append(records, rec)
+	}
+
+	respondWithJSON(w, http.StatusOK, records)
+}
+
+type revenueRow struct {
+	RecordID int     `json:"record_id"`
+	Name     string  `json:"name"`
+	Sold     int     `json:"sold"`
+	Revenue  float64 `json:"revenue"`
+}
+
+type revenueReport struct {
+	Year         string       `json:"year"`
+	Records      []revenueRow `json:"records"`
+	TotalSold    int          `json:"total_sold"`
+	TotalRevenue float64      `json:"total_revenue"`
+}
+
+// GetRevenueHandler reports gross retail revenue per record — units sold
+// times the record's price — plus store-wide totals. ?year=last switches
+// from sold_current_year to sold_last_year. This schema carries a single
+// price per record with no wholesale cost, so there is no margin figure to
+// compute alongside.
+func GetRevenueHandler(w http.ResponseWriter, r *http.Request) {
+	// The sold column is chosen here, never from the query string.
+	soldColumn := "sold_current_year"
+	year := r.URL.Query().Get("year")
+	switch year {
+	case "", "current":
+		year = "current"
+	case "last":
+		soldColumn = "sold_last_year"
+	default:
+		respondWithError(w, http.StatusBadRequest, "year must be current or last")
+		return
+	}
+
+	rows, err := database.DB.Query(
+		"SELECT id, name, " + soldColumn + ", " + soldColumn + " * price FROM records ORDER BY " + soldColumn + " * price DESC, id")
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	defer rows.Close()
+
+	report := revenueReport{Year: year, Records: []revenueRow{}}
+	for rows.Next() {
+		var row revenueRow
+		if err := rows.Scan(&row.RecordID, &row.Name, &row.Sold, &row.Revenue); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		report.Records = append(report.Records, row)
+		report.TotalSold += row.Sold
+		report.TotalRevenue += row.Revenue
+	}
+
+	respondWithJSON(w, http.StatusOK, report)
+}
+
+// GetLowStockHandler lists records whose stock is below the reorder
+// threshold (?threshold=, default 5), scarcest first. Tracks are attached
+// through the shared hydration helper so the report stands on its own.
+// Archived records are excluded — they are not for sale, so they never need
+// reordering.
+func GetLowStockHandler(w http.ResponseWriter, r *http.Request) {
+	threshold := 5
+	if raw := r.URL.Query().Get("threshold"); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v < 1 {
+			respondWithError(w, http.StatusBadRequest, "threshold must be a positive integer")
+			return
+		}
+		threshold = v
+	}
+
+	rows, err := database.DB.Query(
+		"SELECT id, name, price, stock, release_date, date_precision, label, genre, sold_last_year, sold_current_year, weight_grams, max_per_order, archived FROM records WHERE archived = 0 AND stock < ? ORDER BY stock, id",
+		threshold)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	defer rows.Close()
+
+	records := []models.Record{}
+	for rows.Next() {
+		var rec models.Record
+		if err := rows.Scan(&rec.ID, &rec.Name, &rec.Price, &rec.Stock, &rec.ReleaseDate, &rec.DatePrecision, &rec.Label, &rec.Genre, &rec.SoldLastYear, &rec.SoldCurrentYear, &rec.WeightGrams, &rec.MaxPerOrder, &rec.Archived); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		records = append(records, rec)
+	}
+
+	if err := hydrateRecordTracks(records); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"threshold": threshold,
+		"records":   records,
+	})
+}
+
+// GetBestsellersHandler returns records ranked by units sold across all
+// orders. ?releasedAfter= and ?releasedBefore= (YYYY-MM-DD) scope the
+// ranking to a release window. With ?format=csv the same report is streamed
+// as a spreadsheet attachment; JSON stays the default.
+func GetBestsellersHandler(w http.ResponseWriter, r *http.Request) {
+	// release_date is stored canonically as YYYY-MM-DD text, so plain string
+	// comparison orders it correctly; records without a release date fall
+	// outside any window.
+	conditions := []string{}
+	args := []interface{}{}
+	for name, op := range map[string]string{"releasedAfter": ">=", "releasedBefore": "<="} {
+		raw := r.URL.Query().Get(name)
+		if raw == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", raw); err != nil {
+			respondWithError(w, http.StatusBadRequest, name+" must be a YYYY-MM-DD date")
+			return
+		}
+		conditions = append(conditions, "rec.release_date != '' AND rec.release_date "+op+" ?")
+		args = append(args, raw)
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT rec.id, rec.name, COALESCE(SUM(oi.quantity), 0) AS sold,
+			rec.label, rec.price, rec.sold_current_year, rec.sold_last_year
+		FROM records rec
+		LEFT JOIN order_items oi ON oi.record_id = rec.id
+		`+where+`
+		GROUP BY rec.id
+		ORDER BY sold DESC, rec.id`, args...)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	defer rows.Close()
+
+	report := []bestsellerRow{}
+	for rows.Next() {
+		var row bestsellerRow
+		if err := rows.Scan(&row.RecordID, &row.Name, &row.Sold, &row.Label, &row.Price, &row.SoldCurrentYear, &row.SoldLastYear); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		report = append(report, row)
+	}
+
+	if r.URL.Query().Get("format") == "csv" {
+		csvRows := make([][]string, len(report))
+		for i, row := range report {
+			csvRows[i] = []string{
+				strconv.Itoa(row.RecordID), row.Name, strconv.Itoa(row.Sold), row.Label,
+				strconv.FormatFloat(row.Price, 'f', 2, 64),
+				strconv.Itoa(row.SoldCurrentYear), strconv.Itoa(row.SoldLastYear),
+			}
+		}
+		writeCSV(w, "bestsellers.csv",
+			[]string{"record_id", "name", "sold", "label", "price", "sold_current_year", "sold_last_year"},
+			csvRows)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"backend/database"
+)
+
+// yearRolloverDate is the MM-DD date on or after which the annual sales
+// rollover becomes due. Defaults to January 1; override with
+// YEAR_ROLLOVER_DATE for deployments whose business year starts elsewhere.
+var yearRolloverDate = yearRolloverDateFromEnv()
+
+func yearRolloverDateFromEnv() string {
+	if v := os.Getenv("YEAR_ROLLOVER_DATE"); v != "" {
+		if _, err := time.Parse("01-02", v); err == nil {
+			return v
+		}
+		log.Printf("ignoring invalid YEAR_ROLLOVER_DATE %q, using 01-01", v)
+	}
+	return "01-01"
+}
+
+// RunYearRolloverIfDue moves sold_current_year into sold_last_year and zeroes
+// the current counter for every record, once per year. The year it last ran
+// is kept in the job_state table, so the job is idempotent across restarts:
+// if this year's rollover already happened, or the rollover date has not been
+// reached yet, it is a no-op. On a database that has never rolled over it
+// only records the current year as the baseline rather than clobbering
+// freshly loaded counters.
+func RunYearRolloverIfDue() error {
+	now := time.Now().UTC()
+	thisYear := strconv.Itoa(now.Year())
+	if now.Format("2006-01-02") < thisYear+"-"+yearRolloverDate {
+		return nil
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var lastRun string
+	err = tx.QueryRow("SELECT value FROM job_state WHERE name = 'year_rollover'").Scan(&lastRun)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if lastRun == thisYear {
+		return nil
+	}
+
+	if lastRun != "" {
+		if _, err := tx.Exec(
+			"UPDATE records SET sold_last_year = sold_current_year, sold_current_year = 0",
+		); err != nil {
+			return err
+		}
+		log.Printf("year rollover for %s: sales counters rolled", thisYear)
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO job_state (name, value) VALUES ('year_rollover', ?)
+		ON CONFLICT(name) DO UPDATE SET value = excluded.value`, thisYear,
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// StartYearRolloverJob checks for a due rollover at startup and then hourly,
+// so a server running across New Year picks it up without a restart.
+// Failures are logged and retried on the next check.
+func StartYearRolloverJob() {
+	if err := RunYearRolloverIfDue(); err != nil {
+		log.Printf("year rollover failed: %v", err)
+	}
+	go func() {
+		for range time.Tick(time.Hour) {
+			if err := RunYearRolloverIfDue(); err != nil {
+				log.Printf("year rollover failed: %v", err)
+			}
+		}
+	}()
+}
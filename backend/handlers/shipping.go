@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+
+	"backend/database"
+)
+
+// ShippingCalculator estimates the cost of shipping a cart, so carrier
+// integrations can replace the built-in rate table later.
+type ShippingCalculator interface {
+	Estimate(totalGrams int, destination string) (float64, error)
+}
+
+var errUnknownDestination = errors.New("unknown destination")
+
+type shippingRate struct {
+	Base  float64 `json:"base"`
+	PerKg float64 `json:"per_kg"`
+}
+
+// rateTableCalculator charges a per-destination base fee plus a rate per
+// started kilogram.
+type rateTableCalculator struct {
+	rates map[string]shippingRate
+}
+
+func (c rateTableCalculator) Estimate(totalGrams int, destination string) (float64, error) {
+	rate, ok := c.rates[destination]
+	if !ok {
+		return 0, errUnknownDestination
+	}
+	kg := float64(totalGrams) / 1000
+	return rate.Base + rate.PerKg*kg, nil
+}
+
+// shippingCalc is the active calculator. The rate table can be overridden
+// with a JSON object in SHIPPING_RATES, e.g.
+// {"local":{"base":100,"per_kg":20}}.
+var shippingCalc ShippingCalculator = rateTableCalculator{rates: shippingRatesFromEnv()}
+
+func shippingRatesFromEnv() map[string]shippingRate {
+	rates := map[string]shippingRate{
+		"local":         {Base: 150, PerKg: 30},
+		"domestic":      {Base: 300, PerKg: 60},
+		"international": {Base: 900, PerKg: 200},
+	}
+	if raw := os.Getenv("SHIPPING_RATES"); raw != "" {
+		override := map[string]shippingRate{}
+		if err := json.Unmarshal([]byte(raw), &override); err == nil {
+			for dest, rate := range override {
+				rates[dest] = rate
+			}
+		}
+	}
+	return rates
+}
+
+// GetShippingEstimateHandler estimates shipping for the authenticated user's
+// cart based on its total weight and the destination query parameter.
+func GetShippingEstimateHandler(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r)
+
+	destination := r.URL.Query().Get("destination")
+	if destination == "" {
+		respondWithError(w, http.StatusBadRequest, "destination is required")
+		return
+	}
+
+	var totalGrams int
+	err := database.DB.QueryRow(`
+		SELECT COALESCE(SUM(rec.weight_grams * ci.quantity), 0)
+		FROM cart_items ci
+		JOIN records rec ON rec.id = ci.record_id
+		WHERE ci.user_id = ?`, userID).Scan(&totalGrams)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	estimate, err := shippingCalc.Estimate(totalGrams, destination)
+	if err == errUnknownDestination {
+		respondWithError(w, http.StatusBadRequest, "unknown destination")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not estimate shipping")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"destination":  destination,
+		"weight_grams": totalGrams,
+		"estimate":     estimate,
+	})
+}
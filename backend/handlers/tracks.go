@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"backend/database"
+	"backend/models"
+
+	"github.com/gorilla/mux"
+)
+
+type trackPayload struct {
+	Name       string `json:"name"`
+	Duration   int    `json:"duration"`
+	MusicianID *int   `json:"musician_id"`
+	EnsembleID *int   `json:"ensemble_id"`
+}
+
+// CreateTrackHandler adds a track owned by either a musician or an ensemble.
+func CreateTrackHandler(w http.ResponseWriter, r *http.Request) {
+	var payload trackPayload
+	if err := decodeJSONBody(r, &payload); err != nil {
+		if err == errEmptyBody {
+			respondWithError(w, http.StatusBadRequest, "request body is required")
+			return
+		}
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if payload.Name == "" {
+		respondWithError(w, http.StatusBadRequest, "name is required")
+		return
+	}
+	if err := validateName("name", payload.Name); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if payload.MusicianID != nil && payload.EnsembleID != nil {
+		respondWithError(w, http.StatusBadRequest, "track cannot belong to both a musician and an ensemble")
+		return
+	}
+
+	id, err := database.DB.Insert(
+		"INSERT INTO tracks (name, duration, musician_id, ensemble_id) VALUES (?, ?, ?, ?)",
+		sanitizeText(payload.Name), payload.Duration, payload.MusicianID, payload.EnsembleID,
+	)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	bumpCatalogVersion()
+	recordAudit(r, "create", "track", int(id))
+	respondWithJSON(w, http.StatusCreated, map[string]int{"id": int(id)})
+}
+
+// maxTrackDuration bounds plausible track lengths (seconds); anything above
+// two hours is assumed to be bad input.
+const maxTrackDuration = 2 * 60 * 60
+
+type durationUpdate struct {
+	TrackID  int `json:"trackId"`
+	Duration int `json:"duration"`
+}
+
+type durationUpdateResult struct {
+	TrackID int    `json:"trackId"`
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+}
+
+// BulkUpdateTrackDurationsHandler applies a batch of track duration changes
+// in one transaction — the common case after a re-master touches a whole
+// release. Out-of-range durations and unknown track ids are skipped and
+// reported per track; the valid updates still go through.
+func BulkUpdateTrackDurationsHandler(w http.ResponseWriter, r *http.Request) {
+	var updates []durationUpdate
+	if err := decodeJSONBody(r, &updates); err != nil {
+		if err == errEmptyBody {
+			respondWithError(w, http.StatusBadRequest, "request body is required")
+			return
+		}
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if len(updates) == 0 {
+		respondWithError(w, http.StatusBadRequest, "at least one update is required")
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	defer tx.Rollback()
+
+	results := make([]durationUpdateResult, 0, len(updates))
+	updated := 0
+	for _, u := range updates {
+		if u.Duration <= 0 || u.Duration > maxTrackDuration {
+			results = append(results, durationUpdateResult{TrackID: u.TrackID, Status: "skipped", Error: "duration out of range"})
+			continue
+		}
+		res, err := tx.Exec("UPDATE tracks SET duration = ? WHERE id = ?", u.Duration, u.TrackID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			results = append(results, durationUpdateResult{TrackID: u.TrackID, Status: "skipped", Error: "track not found"})
+			continue
+		}
+		results = append(results, durationUpdateResult{TrackID: u.TrackID, Status: "updated"})
+		updated++
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	if updated > 0 {
+		bumpCatalogVersion()
+		recordAudit(r, "update", "track", 0)
+	}
+	respondWithJSON(w, http.StatusOK, results)
+}
+
+type ownerTracks struct {
+	Name   string         `json:"name"`
+	Tracks []models.Track `json:"tracks"`
+}
+
+type groupedTracks struct {
+	ByMusician map[string]*ownerTracks `json:"byMusician"`
+	ByEnsemble map[string]*ownerTracks `json:"byEnsemble"`
+}
+
+// GetGroupedTracksHandler returns all tracks grouped under their owning
+// musician or ensemble, keyed by owner id, so the admin track picker does
+// not have to regroup a flat list.
+func GetGroupedTracksHandler(w http.ResponseWriter, r *http.Request) {
+	grouped := groupedTracks{
+		ByMusician: map[string]*ownerTracks{},
+		ByEnsemble: map[string]*ownerTracks{},
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT t.id, t.name, t.duration, t.musician_id, t.ensemble_id, m.name, e.name
+		FROM tracks t
+		LEFT JOIN musicians m ON m.id = t.musician_id
+		LEFT JOIN ensembles e ON e.id = t.ensemble_id
+		ORDER BY t.id`)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t models.Track
+		var musicianName, ensembleName *string
+		if err := rows.Scan(&t.ID, &t.Name, &t.Duration, &t.MusicianID, &t.EnsembleID, &musicianName, &ensembleName); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		switch {
+		case t.MusicianID != nil:
+			key := strconv.Itoa(*t.MusicianID)
+			if grouped.ByMusician[key] == nil {
+				name := ""
+				if musicianName != nil {
+					name = *musicianName
+				}
+				grouped.ByMusician[key] = &ownerTracks{Name: name, Tracks: []models.Track{}}
+			}
+			grouped.ByMusician[key].Tracks = append(grouped.ByMusician[key].Tracks, t)
+		case t.EnsembleID != nil:
+			key := strconv.Itoa(*t.EnsembleID)
+			if grouped.ByEnsemble[key] == nil {
+				name := ""
+				if ensembleName != nil {
+					name = *ensembleName
+				}
+				grouped.ByEnsemble[key] = &ownerTracks{Name: name, Tracks: []models.Track{}}
+			}
+			grouped.ByEnsemble[key].Tracks = append(grouped.ByEnsemble[key].Tracks, t)
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, grouped)
+}
+
+// GetTrackAbsentRecordsHandler lists the records a track does not appear on,
+// for curating releases that should stay exclusive.
+func GetTrackAbsentRecordsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid track id")
+		return
+	}
+
+	var exists int
+	if err := database.DB.QueryRow("SELECT COUNT(*) FROM tracks WHERE id = ?", id).Scan(&exists); err != nil || exists == 0 {
+		respondWithError(w, http.StatusNotFound, "track not found")
+		return
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT id, name, release_date, label FROM records
+		WHERE id NOT IN (SELECT record_id FROM record_tracks WHERE track_id = ?)
+		ORDER BY id`, id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	defer rows.Close()
+
+	records := []models.Record{}
+	for rows.Next() {
+		var rec models.Record
+		if err := rows.Scan(&rec.ID, &rec.Name, &rec.ReleaseDate, &rec.Label); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		records = append(records, rec)
+	}
+
+	respondWithJSON(w, http.StatusOK, records)
+}
+
+// GetTracksHandler lists tracks in the shared pagination envelope.
+func GetTracksHandler(w http.ResponseWriter, r *http.Request) {
+	var total int
+	if err := database.DB.QueryRow("SELECT COUNT(*) FROM tracks").Scan(&total); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	page, pageSize := parsePagination(r)
+	rows, err := database.DB.Query(
+		"SELECT id, name, duration, musician_id, ensemble_id FROM tracks ORDER BY id LIMIT ? OFFSET ?",
+		pageSize, (page-1)*pageSize,
+	)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	defer rows.Close()
+
+	tracks := []models.Track{}
+	for rows.Next() {
+		var t models.Track
+		if err := rows.Scan(&t.ID, &t.Name, &t.Duration, &t.MusicianID, &t.EnsembleID); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		tracks = append(tracks, t)
+	}
+
+	respondWithJSON(w, http.StatusOK, models.NewPage(tracks, total, page, pageSize))
+}
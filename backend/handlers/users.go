@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"backend/database"
+	"backend/models"
+
+	"github.com/gorilla/mux"
+)
+
+// errLastAdmin is returned when an operation would remove or demote the only
+// remaining administrator.
+var errLastAdmin = errors.New("cannot remove the last administrator")
+
+// rowQuerier lets the guard run against either the shared DB handle or an
+// open transaction.
+type rowQuerier interface {
+	QueryRow(query string, args ...interface{}) *database.Row
+}
+
+// ensureNotLastAdmin fails with errLastAdmin when userID is an admin and no
+// other admin would remain. Every operation that deletes, demotes or merges
+// a user away must call this before writing.
+func ensureNotLastAdmin(q rowQuerier, userID int) error {
+	var role string
+	if err := q.QueryRow("SELECT role FROM users WHERE id = ?", userID).Scan(&role); err != nil {
+		return err
+	}
+	if role != "admin" {
+		return nil
+	}
+	var others int
+	if err := q.QueryRow("SELECT COUNT(*) FROM users WHERE role = 'admin' AND id != ?", userID).Scan(&others); err != nil {
+		return err
+	}
+	if others == 0 {
+		return errLastAdmin
+	}
+	return nil
+}
+
+// GetUsersHandler lists user accounts in the shared pagination envelope, with
+// an optional exact ?role= filter. The rows go out as models.User, whose
+// Password field carries the json:"-" tag, so the hash can never appear in a
+// response even though it sits in the same struct.
+func GetUsersHandler(w http.ResponseWriter, r *http.Request) {
+	where := ""
+	args := []interface{}{}
+	if role := r.URL.Query().Get("role"); role != "" {
+		if role != "client" && role != "admin" {
+			respondWithError(w, http.StatusBadRequest, "role must be client or admin")
+			return
+		}
+		where = " WHERE role = ?"
+		args = append(args, role)
+	}
+
+	var total int
+	if err := database.DB.QueryRow("SELECT COUNT(*) FROM users"+where, args...).Scan(&total); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	page, pageSize := parsePagination(r)
+	rows, err := database.DB.Query(
+		"SELECT id, email, role, wholesale_address, email_verified FROM users"+where+" ORDER BY id LIMIT ? OFFSET ?",
+		append(args, pageSize, (page-1)*pageSize)...,
+	)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		var u models.User
+		if err := rows.Scan(&u.ID, &u.Email, &u.Role, &u.WholesaleAddress, &u.EmailVerified); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+		users = append(users, u)
+	}
+
+	respondWithJSON(w, http.StatusOK, models.NewPage(users, total, page, pageSize))
+}
+
+type rolePayload struct {
+	Role string `json:"role"`
+}
+
+// UpdateUserRoleHandler lets an admin set another user's role; beyond the
+// ADMIN_EMAIL bootstrap in EnsureAdminAccount this is the only way admins are
+// minted. Only the two roles the schema uses are accepted. An admin cannot
+// change their own role: demoting yourself is how a store ends up with no
+// admin at all, and promoting yourself is a no-op anyway, so both come back
+// as 409.
+func UpdateUserRoleHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	var payload rolePayload
+	if err := decodeJSONBody(r, &payload); err != nil {
+		if err == errEmptyBody {
+			respondWithError(w, http.StatusBadRequest, "request body is required")
+			return
+		}
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if payload.Role != "client" && payload.Role != "admin" {
+		respondWithError(w, http.StatusBadRequest, "role must be client or admin")
+		return
+	}
+
+	if id == userIDFromContext(r) {
+		respondWithError(w, http.StatusConflict, "cannot change your own role")
+		return
+	}
+
+	res, err := database.DB.Exec("UPDATE users SET role = ? WHERE id = ?", payload.Role, id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		respondWithError(w, http.StatusNotFound, "user not found")
+		return
+	}
+
+	recordAudit(r, "update", "user", id)
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "updated", "role": payload.Role})
+}
+
+type mergeUsersPayload struct {
+	SourceID int `json:"sourceId"`
+	TargetID int `json:"targetId"`
+}
+
+// MergeUsersHandler merges the source account into the target account: cart
+// lines are combined (quantities summed, capped at stock), orders are
+// reassigned and the source user is deleted, all in one transaction.
+func MergeUsersHandler(w http.ResponseWriter, r *http.Request) {
+	var payload mergeUsersPayload
+	if err := decodeJSONBody(r, &payload); err != nil {
+		if err == errEmptyBody {
+			respondWithError(w, http.StatusBadRequest, "request body is required")
+			return
+		}
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if payload.SourceID == payload.TargetID {
+		respondWithError(w, http.StatusBadRequest, "source and target must be different users")
+		return
+	}
+
+	var sourceRole string
+	err := database.DB.QueryRow("SELECT role FROM users WHERE id = ?", payload.SourceID).Scan(&sourceRole)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "source user not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	var targetExists int
+	if err := database.DB.QueryRow("SELECT COUNT(*) FROM users WHERE id = ?", payload.TargetID).Scan(&targetExists); err != nil || targetExists == 0 {
+		respondWithError(w, http.StatusNotFound, "target user not found")
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	defer tx.Rollback()
+
+	if err := ensureNotLastAdmin(tx, payload.SourceID); err != nil {
+		if err == errLastAdmin {
+			respondWithError(w, http.StatusBadRequest, errLastAdmin.Error())
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	// Fold the source cart into the target cart, then cap every merged line
+	// at the record's current stock.
+	if _, err := tx.Exec(`
+		INSERT INTO cart_items (user_id, record_id, quantity)
+		SELECT ?, record_id, quantity FROM cart_items WHERE user_id = ?
+		ON CONFLICT(user_id, record_id) DO UPDATE SET quantity = quantity + excluded.quantity`,
+		payload.TargetID, payload.SourceID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	if _, err := tx.Exec(`
+		UPDATE cart_items SET quantity = (SELECT stock FROM records WHERE id = record_id)
+		WHERE user_id = ? AND quantity > (SELECT stock FROM records WHERE id = record_id)`,
+		payload.TargetID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	if _, err := tx.Exec("DELETE FROM cart_items WHERE user_id = ?", payload.SourceID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	if _, err := tx.Exec("UPDATE orders SET user_id = ? WHERE user_id = ?", payload.TargetID, payload.SourceID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	if _, err := tx.Exec("DELETE FROM users WHERE id = ?", payload.SourceID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	recordAudit(r, "merge", "user", payload.SourceID)
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "merged"})
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"html"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// maxBoundParams caps how many values go into a single IN (...) clause.
+// SQLite builds have historically limited a statement to 999 bound
+// parameters, and exceeding that fails with an opaque driver error — so
+// batch queries split longer id lists into chunks of this size and merge
+// the results.
+const maxBoundParams = 500
+
+// forEachIDChunk calls fn once per chunk of at most maxBoundParams ids,
+// passing a matching "?,?,..." placeholder list and the chunk as query
+// arguments. The first error aborts the iteration.
+func forEachIDChunk(ids []int, fn func(placeholders string, args []interface{}) error) error {
+	for start := 0; start < len(ids); start += maxBoundParams {
+		end := start + maxBoundParams
+		if end > len(ids) {
+			end = len(ids)
+		}
+		chunk := ids[start:end]
+		args := make([]interface{}, len(chunk))
+		for i, id := range chunk {
+			args[i] = id
+		}
+		placeholders := strings.TrimRight(strings.Repeat("?,", len(chunk)), ",")
+		if err := fn(placeholders, args); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// maxNameLen caps name/title input across records, tracks, musicians and
+// ensembles. SQLite itself puts no length limit on TEXT, so without this a
+// pathological multi-megabyte title would be stored and bloat every listing.
+// Override with MAX_NAME_LENGTH.
+var maxNameLen = maxNameLenFromEnv()
+
+func maxNameLenFromEnv() int {
+	if v, err := strconv.Atoi(os.Getenv("MAX_NAME_LENGTH")); err == nil && v > 0 {
+		return v
+	}
+	return 500
+}
+
+// validateName rejects over-length names; field names the offending input in
+// the error message.
+func validateName(field, value string) error {
+	if len(value) > maxNameLen {
+		return errors.New(field + " must be at most " + strconv.Itoa(maxNameLen) + " characters")
+	}
+	return nil
+}
+
+// minPasswordLen applies wherever a password is set — registration and
+// password change — never at login, so accounts created before the rule
+// still work. Override with MIN_PASSWORD_LENGTH.
+var minPasswordLen = minPasswordLenFromEnv()
+
+func minPasswordLenFromEnv() int {
+	if v, err := strconv.Atoi(os.Getenv("MIN_PASSWORD_LENGTH")); err == nil && v > 0 {
+		return v
+	}
+	return 8
+}
+
+// validatePassword enforces the password policy: the configured minimum
+// length plus at least one letter and one digit.
+func validatePassword(password string) error {
+	if len(password) < minPasswordLen {
+		return errors.New("password must be at least " + strconv.Itoa(minPasswordLen) + " characters")
+	}
+	hasLetter, hasDigit := false, false
+	for _, r := range password {
+		switch {
+		case unicode.IsLetter(r):
+			hasLetter = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		}
+	}
+	if !hasLetter || !hasDigit {
+		return errors.New("password must contain at least one letter and one digit")
+	}
+	return nil
+}
+
+// sanitizeText HTML-escapes user-provided free text before storage, so a
+// title like "<script>..." can never be echoed back as live markup to a
+// client that renders responses verbatim. Every create/update path that
+// stores names, labels or addresses must pass the value through here. The
+// unescape first makes it idempotent: an edit form that submits the stored
+// value back unchanged ("Tom &amp; Jerry") round-trips to the same bytes
+// instead of gaining another layer of escaping on every save.
+func sanitizeText(value string) string {
+	return html.EscapeString(html.UnescapeString(value))
+}
+
+// errEmptyBody is returned by decodeJSONBody when the request carried no body
+// at all, so handlers can distinguish "nothing sent" from malformed JSON.
+var errEmptyBody = errors.New("request body is required")
+
+// decodeJSONBody decodes the request body into dst. A completely empty body
+// yields errEmptyBody; any other decode failure is returned as-is. Unknown
+// fields are rejected, so a typo like "quantaty" fails loudly as a 400
+// instead of being silently dropped.
+func decodeJSONBody(r *http.Request, dst interface{}) error {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(dst)
+	if errors.Is(err, io.EOF) {
+		return errEmptyBody
+	}
+	return err
+}
+
+// Pagination bounds, also reported to the frontend via /api/config.
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// parsePagination reads the page and pageSize query parameters, applying the
+// default page size and cap above.
+func parsePagination(r *http.Request) (page, pageSize int) {
+	page, pageSize = 1, defaultPageSize
+	if v, err := strconv.Atoi(r.URL.Query().Get("page")); err == nil && v > 0 {
+		page = v
+	}
+	if v, err := strconv.Atoi(r.URL.Query().Get("pageSize")); err == nil && v > 0 && v <= maxPageSize {
+		pageSize = v
+	}
+	return page, pageSize
+}
+
+// writeCSV streams the given rows as a CSV attachment.
+func writeCSV(w http.ResponseWriter, filename string, header []string, rows [][]string) {
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	cw := csv.NewWriter(w)
+	cw.Write(header)
+	for _, row := range rows {
+		cw.Write(row)
+	}
+	cw.Flush()
+}
+
+func respondWithError(w http.ResponseWriter, code int, message string) {
+	respondWithJSON(w, code, map[string]string{"error": message})
+}
+
+func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
+	response, err := json.Marshal(payload)
+	if err != nil {
+		http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(response)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"backend/database"
+	"backend/handlers"
+
+	"github.com/gorilla/mux"
+)
+
+// shutdownTimeout is how long in-flight requests get to finish once a
+// shutdown signal arrives before the server gives up on them.
+const shutdownTimeout = 10 * time.Second
+
+// registerAPIRoutes attaches every API route to the given subrouter so the
+// same handlers can be served under more than one path prefix.
+func registerAPIRoutes(api *mux.Router) {
+	api.Use(handlers.RateLimitMiddleware, handlers.ConcurrencyLimitMiddleware, handlers.BodyLimitMiddleware, handlers.JSONContentTypeMiddleware, handlers.CacheControlMiddleware)
+
+	// Public routes.
+	api.HandleFunc("/config", handlers.GetConfigHandler).Methods("GET")
+	api.HandleFunc("/register", handlers.RegisterHandler).Methods("POST")
+	api.HandleFunc("/login", handlers.LoginHandler).Methods("POST")
+	api.HandleFunc("/refresh", handlers.RefreshTokenHandler).Methods("POST")
+	api.HandleFunc("/password-reset/request", handlers.RequestPasswordResetHandler).Methods("POST")
+	api.HandleFunc("/password-reset/confirm", handlers.ConfirmPasswordResetHandler).Methods("POST")
+	api.HandleFunc("/verify-email", handlers.VerifyEmailHandler).Methods("POST")
+	api.HandleFunc("/records", handlers.GetRecordsHandler).Methods("GET")
+	api.HandleFunc("/records/{id}", handlers.GetRecordHandler).Methods("GET")
+	api.HandleFunc("/records/{id}/price-history", handlers.GetRecordPriceHistoryHandler).Methods("GET")
+
+	// Routes for any authenticated user.
+	auth := api.NewRoute().Subrouter()
+	auth.Use(handlers.AuthMiddleware)
+	auth.HandleFunc("/token/reissue", handlers.ReissueTokenHandler).Methods("POST")
+	auth.HandleFunc("/logout", handlers.LogoutHandler).Methods("POST")
+	auth.HandleFunc("/profile", handlers.GetProfileHandler).Methods("GET")
+	auth.HandleFunc("/profile", handlers.UpdateProfileHandler).Methods("PUT", "PATCH")
+	auth.HandleFunc("/profile", handlers.DeleteProfileHandler).Methods("DELETE")
+	auth.HandleFunc("/profile/password", handlers.ChangePasswordHandler).Methods("PUT")
+	auth.HandleFunc("/cart", handlers.GetCartHandler).Methods("GET")
+	auth.HandleFunc("/cart/total", handlers.GetCartTotalHandler).Methods("GET")
+	auth.HandleFunc("/cart/shipping", handlers.GetShippingEstimateHandler).Methods("GET")
+	auth.HandleFunc("/cart", handlers.AddToCartHandler).Methods("POST")
+	auth.HandleFunc("/cart/{recordId}", handlers.UpdateCartHandler).Methods("PUT")
+	auth.HandleFunc("/cart", handlers.ClearCartHandler).Methods("DELETE")
+	auth.HandleFunc("/cart/{recordId}", handlers.RemoveFromCartHandler).Methods("DELETE")
+	auth.HandleFunc("/orders/checkout", handlers.CheckoutHandler).Methods("POST")
+	auth.HandleFunc("/orders", handlers.GetOrdersHandler).Methods("GET")
+	auth.HandleFunc("/orders/{id}", handlers.GetOrderHandler).Methods("GET")
+
+	// Admin-only routes.
+	admin := api.PathPrefix("/admin").Subrouter()
+	admin.Use(handlers.AuthMiddleware, handlers.AdminMiddleware)
+	admin.HandleFunc("/records", handlers.CreateRecordHandler).Methods("POST")
+	admin.HandleFunc("/records/{id}/full", handlers.GetFullRecordHandler).Methods("GET")
+	admin.HandleFunc("/records/{id}/suggested-tracks", handlers.GetSuggestedTracksHandler).Methods("GET")
+	admin.HandleFunc("/records/{id}/orders", handlers.GetRecordOrdersHandler).Methods("GET")
+	admin.HandleFunc("/records/{id}", handlers.UpdateRecordHandler).Methods("PUT")
+	admin.HandleFunc("/records/{id}", handlers.DeleteRecordHandler).Methods("DELETE")
+	admin.HandleFunc("/records/compare", handlers.CompareRecordsHandler).Methods("GET")
+	admin.HandleFunc("/records/merge", handlers.MergeRecordsHandler).Methods("POST")
+	admin.HandleFunc("/records/recompute-sales", handlers.RecomputeSalesHandler).Methods("POST")
+	admin.HandleFunc("/records/import/preview", handlers.PreviewRecordsImportHandler).Methods("POST")
+	admin.HandleFunc("/records/import", handlers.ImportRecordsHandler).Methods("POST")
+	admin.HandleFunc("/tracks", handlers.CreateTrackHandler).Methods("POST")
+	admin.HandleFunc("/tracks", handlers.GetTracksHandler).Methods("GET")
+	admin.HandleFunc("/tracks/grouped", handlers.GetGroupedTracksHandler).Methods("GET")
+	admin.HandleFunc("/tracks/durations/bulk", handlers.BulkUpdateTrackDurationsHandler).Methods("POST")
+	admin.HandleFunc("/tracks/{id}/absent-records", handlers.GetTrackAbsentRecordsHandler).Methods("GET")
+	admin.HandleFunc("/ensembles", handlers.CreateEnsembleHandler).Methods("POST")
+	admin.HandleFunc("/ensembles", handlers.GetEnsemblesHandler).Methods("GET")
+	admin.HandleFunc("/ensembles/{id}", handlers.UpdateEnsembleHandler).Methods("PUT")
+	admin.HandleFunc("/ensembles/{id}", handlers.DeleteEnsembleHandler).Methods("DELETE")
+	admin.HandleFunc("/ensembles/merge", handlers.MergeEnsemblesHandler).Methods("POST")
+	admin.HandleFunc("/ensembles/{id}/members/bulk", handlers.BulkAssignMembersHandler).Methods("POST")
+	admin.HandleFunc("/ensembles/{id}/musicians", handlers.GetEnsembleMusiciansHandler).Methods("GET")
+	admin.HandleFunc("/ensembles/{id}/archive", handlers.ArchiveEnsembleHandler).Methods("POST")
+	admin.HandleFunc("/ensembles/{id}/unarchive", handlers.UnarchiveEnsembleHandler).Methods("POST")
+	admin.HandleFunc("/ensembles/{id}/track-count", handlers.GetEnsembleTrackCountHandler).Methods("GET")
+	admin.HandleFunc("/ensembles/{id}/tracks", handlers.GetEnsembleTracksHandler).Methods("GET")
+	admin.HandleFunc("/musicians", handlers.CreateMusicianHandler).Methods("POST")
+	admin.HandleFunc("/musicians", handlers.GetMusiciansHandler).Methods("GET")
+	admin.HandleFunc("/musicians/{id}", handlers.UpdateMusicianHandler).Methods("PUT")
+	admin.HandleFunc("/musicians/{id}", handlers.DeleteMusicianHandler).Methods("DELETE")
+	admin.HandleFunc("/musicians/import", handlers.ImportMusiciansHandler).Methods("POST")
+	admin.HandleFunc("/orders", handlers.GetAllOrdersHandler).Methods("GET")
+	admin.HandleFunc("/users", handlers.GetUsersHandler).Methods("GET")
+	admin.HandleFunc("/users/merge", handlers.MergeUsersHandler).Methods("POST")
+	admin.HandleFunc("/users/{id}/role", handlers.UpdateUserRoleHandler).Methods("PUT")
+	admin.HandleFunc("/audit-log", handlers.GetAuditLogHandler).Methods("GET")
+	admin.HandleFunc("/export/full", handlers.ExportFullCatalogHandler).Methods("GET")
+	admin.HandleFunc("/import/full", handlers.ImportFullCatalogHandler).Methods("POST")
+	admin.HandleFunc("/reports/bestsellers", handlers.GetBestsellersHandler).Methods("GET")
+	admin.HandleFunc("/reports/revenue", handlers.GetRevenueHandler).Methods("GET")
+	admin.HandleFunc("/reports/low-stock", handlers.GetLowStockHandler).Methods("GET")
+	admin.HandleFunc("/reports/cart-activity", handlers.GetCartActivityHandler).Methods("GET")
+	admin.HandleFunc("/reports/by-label", handlers.GetLabelSummariesHandler).Methods("GET")
+	admin.HandleFunc("/reports/ensemble-track-counts", handlers.GetEnsembleTrackCountsHandler).Methods("GET")
+	admin.HandleFunc("/reports/data-integrity", handlers.GetDataIntegrityHandler).Methods("GET")
+	admin.HandleFunc("/reports/records-by-artist", handlers.GetRecordsByArtistHandler).Methods("GET")
+	admin.HandleFunc("/reports/inventory-value", handlers.GetInventoryValueHandler).Methods("GET")
+}
+
+func main() {
+	if err := database.InitDB("store.db"); err != nil {
+		log.Fatalf("failed to initialize database: %v", err)
+	}
+	if err := handlers.EnsureAdminAccount(); err != nil {
+		log.Fatalf("admin bootstrap failed: %v", err)
+	}
+
+	handlers.StartInventorySnapshotJob()
+	handlers.StartYearRolloverJob()
+	handlers.StartRevokedTokenPurgeJob()
+
+	r := mux.NewRouter()
+
+	// The versioned prefix must come first or the bare /api prefix would
+	// swallow /api/v1/... paths. Both serve the same handlers while clients
+	// migrate.
+	registerAPIRoutes(r.PathPrefix("/api/v1").Subrouter())
+	registerAPIRoutes(r.PathPrefix("/api").Subrouter())
+
+	srv := &http.Server{Addr: ":8080", Handler: handlers.CORSMiddleware(r)}
+
+	// On SIGINT/SIGTERM, stop accepting connections, let in-flight requests
+	// drain for up to shutdownTimeout, and only then close the database —
+	// killing the process mid-write is how SQLite files end up needing
+	// recovery.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		log.Println("server listening on :8080")
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("server error: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+	log.Println("shutting down")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("shutdown did not finish cleanly: %v", err)
+	}
+	if err := database.DB.Close(); err != nil {
+		log.Printf("closing database: %v", err)
+	}
+}
@@ -0,0 +1,80 @@
+package models
+
+// User is an account in the shop. Password holds the bcrypt hash and is
+// never serialized back to the client.
+type User struct {
+	ID               int    `json:"id"`
+	Email            string `json:"email"`
+	Password         string `json:"-"`
+	Role             string `json:"role"`
+	WholesaleAddress string `json:"wholesale_address,omitempty"`
+	EmailVerified    bool   `json:"email_verified"`
+}
+
+// Musician is a solo performer who can own tracks and play in ensembles.
+type Musician struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// Ensemble is a group of musicians that can own tracks. Archived ensembles
+// are hidden from active listings but keep their tracks and records.
+type Ensemble struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	Archived bool   `json:"archived"`
+}
+
+// Track belongs to either a musician or an ensemble. Duration is in seconds.
+type Track struct {
+	ID         int    `json:"id"`
+	Name       string `json:"name"`
+	Duration   int    `json:"duration"`
+	MusicianID *int   `json:"musician_id,omitempty"`
+	EnsembleID *int   `json:"ensemble_id,omitempty"`
+}
+
+// Record is a vinyl record in the catalog.
+type Record struct {
+	ID              int     `json:"id"`
+	Name            string  `json:"name"`
+	Price           float64 `json:"price"`
+	Stock           int     `json:"stock"`
+	ReleaseDate     string  `json:"release_date"`
+	DatePrecision   string  `json:"date_precision"`
+	Label           string  `json:"label"`
+	Genre           string  `json:"genre"`
+	SoldLastYear    int     `json:"sold_last_year"`
+	SoldCurrentYear int     `json:"sold_current_year"`
+	WeightGrams     int     `json:"weight_grams"`
+	MaxPerOrder     int     `json:"max_per_order"`
+	Archived        bool    `json:"archived"`
+	Tracks          []Track `json:"tracks,omitempty"`
+}
+
+// CartItem is one line of a user's cart, denormalized with record details
+// for display.
+type CartItem struct {
+	RecordID  int     `json:"record_id"`
+	Name      string  `json:"name"`
+	Price     float64 `json:"price"`
+	Quantity  int     `json:"quantity"`
+	LineTotal float64 `json:"lineTotal"`
+}
+
+// Order is a completed checkout.
+type Order struct {
+	ID        int         `json:"id"`
+	UserID    int         `json:"user_id"`
+	CreatedAt string      `json:"created_at"`
+	Total     float64     `json:"total"`
+	Items     []OrderItem `json:"items,omitempty"`
+}
+
+// OrderItem is one line of an order, with the price captured at checkout.
+type OrderItem struct {
+	RecordID int     `json:"record_id"`
+	Name     string  `json:"name"`
+	Quantity int     `json:"quantity"`
+	Price    float64 `json:"price"`
+}
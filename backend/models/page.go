@@ -0,0 +1,27 @@
+package models
+
+// Page is the shared pagination envelope returned by paginated listings, so
+// clients only ever have one wrapper shape to parse.
+type Page struct {
+	Items      interface{} `json:"items"`
+	Total      int         `json:"total"`
+	Page       int         `json:"page"`
+	PageSize   int         `json:"pageSize"`
+	TotalPages int         `json:"totalPages"`
+}
+
+// NewPage assembles a pagination envelope, computing totalPages as the
+// ceiling of total divided by pageSize.
+func NewPage(items interface{}, total, page, pageSize int) Page {
+	totalPages := 0
+	if pageSize > 0 {
+		totalPages = (total + pageSize - 1) / pageSize
+	}
+	return Page{
+		Items:      items,
+		Total:      total,
+		Page:       page,
+		PageSize:   pageSize,
+		TotalPages: totalPages,
+	}
+}